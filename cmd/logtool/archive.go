@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/liuxiaodao666/go-util/logger/logfile"
+)
+
+func runList(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	file := fileFlag(fs)
+	fs.Parse(args)
+
+	files, err := logfile.List(*file)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	var total int64
+	for _, f := range files {
+		mark := ""
+		if f.Active {
+			mark = "active"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", f.ModTime.Format("2006-01-02 15:04:05"), formatSize(f.Size), f.Path, mark)
+		total += f.Size
+	}
+	fmt.Fprintf(tw, "\t%s\t%d files\t\n", formatSize(total), len(files))
+	return tw.Flush()
+}
+
+func runDecompress(args []string) error {
+	fs := flag.NewFlagSet("decompress", flag.ExitOnError)
+	out := fs.String("o", "", "output file; only with a single archive")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		return errors.New("no archive given")
+	}
+	if *out != "" && fs.NArg() > 1 {
+		return errors.New("-o needs a single archive")
+	}
+	for _, path := range fs.Args() {
+		dst, err := logfile.Decompress(path, *out)
+		if err != nil {
+			return err
+		}
+		fmt.Println(dst)
+	}
+	return nil
+}
+
+func runSearch(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	file := fileFlag(fs)
+	ignoreCase := fs.Bool("i", false, "case-insensitive match")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return errors.New("usage: logtool search [-file name] [-i] regexp")
+	}
+	expr := fs.Arg(0)
+	if *ignoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return err
+	}
+	return logfile.Search(*file, re, func(m logfile.Match) bool {
+		fmt.Printf("%s:%d: %s\n", m.Path, m.Line, m.Text)
+		return true
+	})
+}
+
+func runPrune(args []string) error {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	file := fileFlag(fs)
+	maxSize := fs.String("max-size", "", "disk quota for all files, e.g. 500MB")
+	maxFiles := fs.Int("max-files", 0, "number of archives to keep")
+	maxAge := fs.Duration("max-age", 0, "delete archives older than this, e.g. 168h")
+	dryRun := fs.Bool("dry-run", false, "only print what would be deleted")
+	fs.Parse(args)
+
+	p := logfile.Policy{MaxFiles: *maxFiles, MaxAge: *maxAge, DryRun: *dryRun}
+	if *maxSize != "" {
+		size, err := parseSize(*maxSize)
+		if err != nil {
+			return err
+		}
+		p.MaxTotalSize = size
+	}
+	if p.MaxTotalSize == 0 && p.MaxFiles == 0 && p.MaxAge == 0 {
+		return errors.New("no policy given: set -max-size, -max-files or -max-age")
+	}
+
+	removed, err := logfile.Prune(*file, p, time.Now())
+	deleted, truncated := "deleted", "truncated active file"
+	if *dryRun {
+		deleted, truncated = "would delete", "would truncate active file"
+	}
+	for _, f := range removed {
+		verb := deleted
+		if f.Active {
+			verb = truncated
+		}
+		fmt.Printf("%s %s (%s)\n", verb, f.Path, formatSize(f.Size))
+	}
+	return err
+}
+
+var sizeUnits = []struct {
+	suffix string
+	n      int64
+}{
+	{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"B", 1},
+}
+
+// parseSize parses "1024", "100KB", "500MB" or "2G".
+func parseSize(s string) (int64, error) {
+	upper := strings.ToUpper(strings.TrimSpace(s))
+	mult := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(upper, u.suffix) {
+			upper, mult = strings.TrimSuffix(upper, u.suffix), u.n
+			break
+		}
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(upper), 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * mult, nil
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1fG", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fM", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fK", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", n)
+}
@@ -0,0 +1,76 @@
+// Command logtool manages the files written by package logger.
+//
+//	logtool list       [-file name]
+//	logtool decompress [-o dst] archive.gz...
+//	logtool search     [-file name] [-i] regexp
+//	logtool prune      [-file name] [-max-size 500MB] [-max-files 10] [-max-age 168h] [-dry-run]
+//	logtool tail       [-file name] [-n 10] [-f] [filters]
+//	logtool query      [-file name] [-limit n] [filters]
+//	logtool verify     [-file ./log/audit.log] [-key-env LOG_AUDIT_KEY] [-json]
+//
+// The filters are -level warn, -since 2h or RFC3339, -until, -caller gopool/ and
+// -grep regexp; -o selects color, plain or json output.
+//
+// Except for verify, -file defaults to $LOG_FILE, then ./log/test.log. It may also be a rotation
+// pattern such as "./log/app-%Y-%m-%d.log".
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"list":       {"list the log file and its archives", runList},
+	"decompress": {"decompress .gz archives", runDecompress},
+	"search":     {"search the log file and its archives", runSearch},
+	"prune":      {"delete archives by quota, count and age", runPrune},
+	"tail":       {"print the last entries and follow the active file", runTail},
+	"query":      {"filter entries across the log file and its archives", runQuery},
+	"verify":     {"check the hash chain of an audit log", runVerify},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "logtool: unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "logtool %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: logtool <command> [flags]")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-11s %s\n", name, commands[name].usage)
+	}
+}
+
+// fileFlag adds the -file flag shared by the commands.
+func fileFlag(fs *flag.FlagSet) *string {
+	def := os.Getenv("LOG_FILE")
+	if def == "" {
+		def = "./log/test.log"
+	}
+	return fs.String("file", def, "log file name or rotation pattern")
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/liuxiaodao666/go-util/logger/logfile"
+)
+
+// filterFlags adds the flags shared by tail and query.
+type filterFlags struct {
+	level, since, until, caller, grep, output *string
+}
+
+func addFilterFlags(fs *flag.FlagSet) filterFlags {
+	return filterFlags{
+		level:  fs.String("level", "", "minimum level: debug, info, warn, error"),
+		since:  fs.String("since", "", "entries at or after this time, RFC3339 or a duration ago like 2h"),
+		until:  fs.String("until", "", "entries at or before this time, RFC3339 or a duration ago"),
+		caller: fs.String("caller", "", "entries whose caller contains this, e.g. gopool/"),
+		grep:   fs.String("grep", "", "entries matching this regexp"),
+		output: fs.String("o", "", "output: color, plain or json; default color on a terminal"),
+	}
+}
+
+func (ff filterFlags) filter(now time.Time) (logfile.Filter, error) {
+	f := logfile.Filter{Level: *ff.level, Caller: *ff.caller}
+	if f.Level != "" && !logfile.ValidLevel(f.Level) {
+		return f, fmt.Errorf("unknown level %q", f.Level)
+	}
+	var err error
+	if f.Since, err = parseTime(*ff.since, now); err != nil {
+		return f, err
+	}
+	if f.Until, err = parseTime(*ff.until, now); err != nil {
+		return f, err
+	}
+	if *ff.grep != "" {
+		if f.Pattern, err = regexp.Compile(*ff.grep); err != nil {
+			return f, err
+		}
+	}
+	return f, nil
+}
+
+// parseTime accepts RFC3339, "2006-01-02 15:04:05" in local time, or a duration before now.
+func parseTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+func (ff filterFlags) printer(w io.Writer) (func(logfile.Entry), error) {
+	mode := *ff.output
+	if mode == "" {
+		mode = "plain"
+		if isTerminal(os.Stdout) {
+			mode = "color"
+		}
+	}
+	switch mode {
+	case "plain":
+		return func(e logfile.Entry) { fmt.Fprintln(w, e.Raw) }, nil
+	case "color":
+		return func(e logfile.Entry) { printColor(w, e) }, nil
+	case "json":
+		enc := json.NewEncoder(w)
+		return func(e logfile.Entry) { enc.Encode(entryJSON(e)) }, nil
+	}
+	return nil, fmt.Errorf("unknown output %q", mode)
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// levelColors match zap's CapitalColorLevelEncoder.
+var levelColors = map[string]string{
+	"DEBUG": "\x1b[35m", "INFO": "\x1b[34m", "WARN": "\x1b[33m",
+	"ERROR": "\x1b[31m", "DPANIC": "\x1b[31m", "PANIC": "\x1b[31m", "FATAL": "\x1b[31m",
+}
+
+const (
+	dim   = "\x1b[2m"
+	reset = "\x1b[0m"
+)
+
+func printColor(w io.Writer, e logfile.Entry) {
+	if e.Level == "" {
+		fmt.Fprintln(w, e.Raw)
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s%s%s %s%-5s%s", dim, e.Time.Format(time.RFC3339), reset, levelColors[e.Level], e.Level, reset)
+	if e.Logger != "" {
+		fmt.Fprintf(&b, " [%s]", e.Logger)
+	}
+	if e.Caller != "" {
+		fmt.Fprintf(&b, " %s%s%s", dim, e.Caller, reset)
+	}
+	b.WriteString(" " + e.Message)
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v, _ := json.Marshal(e.Fields[k])
+		fmt.Fprintf(&b, " %s%s=%s%s", dim, k, reset, v)
+	}
+	if e.Stack != "" {
+		b.WriteString("\n" + dim + e.Stack + reset)
+	}
+	fmt.Fprintln(w, b.String())
+}
+
+// entryJSON lays e out like the logger's json encoder.
+func entryJSON(e logfile.Entry) map[string]interface{} {
+	m := map[string]interface{}{}
+	for k, v := range e.Fields {
+		m[k] = v
+	}
+	if e.Level == "" {
+		m["msg"] = e.Raw
+		return m
+	}
+	m["ts"] = e.Time.Format(time.RFC3339)
+	m["level"] = e.Level
+	m["msg"] = e.Message
+	if e.Logger != "" {
+		m["logger"] = e.Logger
+	}
+	if e.Caller != "" {
+		m["caller"] = e.Caller
+	}
+	if e.Stack != "" {
+		m["stacktrace"] = e.Stack
+	}
+	return m
+}
+
+func runTail(args []string) error {
+	fs := flag.NewFlagSet("tail", flag.ExitOnError)
+	file := fileFlag(fs)
+	lines := fs.Int("n", 10, "number of entries to print first")
+	follow := fs.Bool("f", false, "follow the active file across rotations")
+	ff := addFilterFlags(fs)
+	fs.Parse(args)
+
+	filter, err := ff.filter(time.Now())
+	if err != nil {
+		return err
+	}
+	emit, err := ff.printer(os.Stdout)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return logfile.Tail(ctx, *file, logfile.TailOptions{Lines: *lines, Follow: *follow, Filter: filter}, emit)
+}
+
+func runQuery(args []string) error {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	file := fileFlag(fs)
+	limit := fs.Int("limit", 0, "stop after this many entries")
+	ff := addFilterFlags(fs)
+	fs.Parse(args)
+
+	if fs.NArg() > 0 {
+		return errors.New("unexpected arguments: " + strings.Join(fs.Args(), " "))
+	}
+	filter, err := ff.filter(time.Now())
+	if err != nil {
+		return err
+	}
+	emit, err := ff.printer(os.Stdout)
+	if err != nil {
+		return err
+	}
+	n := 0
+	return logfile.Query(*file, filter, func(e logfile.Entry) bool {
+		emit(e)
+		n++
+		return *limit <= 0 || n < *limit
+	})
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/liuxiaodao666/go-util/logger/logfile"
+)
+
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	file := fs.String("file", "./log/audit.log", "audit log file name or rotation pattern")
+	keyEnv := fs.String("key-env", "LOG_AUDIT_KEY", "environment variable holding the HMAC key")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	fs.Parse(args)
+
+	rep, err := logfile.VerifyAudit(*file, []byte(os.Getenv(*keyEnv)))
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(rep); err != nil {
+			return err
+		}
+	} else {
+		for _, p := range rep.Problems {
+			fmt.Println(p)
+		}
+		fmt.Printf("%d entries in %d files, last seq %d, last hash %s\n",
+			rep.Entries, rep.Files, rep.LastSeq, rep.LastHash)
+	}
+	if !rep.OK() {
+		return errors.New("audit chain is broken")
+	}
+	return nil
+}
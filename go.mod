@@ -3,13 +3,14 @@ module github.com/liuxiaodao666/go-util
 go 1.17
 
 require (
+	github.com/BurntSushi/toml v1.0.0
 	go.uber.org/zap v1.21.0
 	golang.org/x/image v0.23.0
 	gopkg.in/natefinch/lumberjack.v2 v2.0.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
-	github.com/BurntSushi/toml v1.0.0 // indirect
 	go.uber.org/atomic v1.7.0 // indirect
 	go.uber.org/multierr v1.6.0 // indirect
 	golang.org/x/text v0.21.0 // indirect
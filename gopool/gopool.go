@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/liuxiaodao666/go-util/logger"
 	"sync"
+	"time"
 )
 
 // Job is an interface that represents a unit of work to be executed by a worker.
@@ -12,21 +13,128 @@ type Job interface {
 	Run(ctx context.Context) error
 }
 
+// Clock is the source of time used by the worker pool.
+// Tests can replace it with a fake clock, see gopool/gopooltest.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Hooks are optional callbacks fired on pool events. They are called synchronously,
+// so they should return quickly. OnSubmit is called before the job is queued, so it
+// always precedes the job's OnDone.
+type Hooks struct {
+	OnSubmit func(job Job)
+	OnDrop   func(job Job)
+	OnDone   func(job Job, err error)
+}
+
+// Option configures a WorkerPool.
+type Option func(*WorkerPool)
+
+// WithClock sets the clock used for job timeouts.
+func WithClock(c Clock) Option {
+	return func(wp *WorkerPool) {
+		wp.clock = c
+	}
+}
+
+// WithJobTimeout cancels the context passed to each job after d with
+// context.DeadlineExceeded, measured on the pool's clock. Zero means no timeout.
+func WithJobTimeout(d time.Duration) Option {
+	return func(wp *WorkerPool) {
+		wp.jobTimeout = d
+	}
+}
+
+// WithHooks registers callbacks for submitted, dropped and finished jobs.
+// Hooks from several WithHooks options are all called, in the order given.
+func WithHooks(h Hooks) Option {
+	return func(wp *WorkerPool) {
+		wp.hooks = chainHooks(wp.hooks, h)
+	}
+}
+
+// chainHooks returns hooks calling a and then b.
+func chainHooks(a, b Hooks) Hooks {
+	return Hooks{
+		OnSubmit: chainJob(a.OnSubmit, b.OnSubmit),
+		OnDrop:   chainJob(a.OnDrop, b.OnDrop),
+		OnDone:   chainDone(a.OnDone, b.OnDone),
+	}
+}
+
+func chainJob(a, b func(Job)) func(Job) {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	return func(job Job) {
+		a(job)
+		b(job)
+	}
+}
+
+func chainDone(a, b func(Job, error)) func(Job, error) {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	return func(job Job, err error) {
+		a(job, err)
+		b(job, err)
+	}
+}
+
+// WithSyncMode disables the worker goroutines. Queued jobs only run when RunOne is called,
+// which lets tests step through the pool one job at a time.
+func WithSyncMode() Option {
+	return func(wp *WorkerPool) {
+		wp.sync = true
+	}
+}
+
 // WorkerPool is the main structure that holds the pool of workers.
 type WorkerPool struct {
 	taskQueue chan Job
 	//wg        sync.WaitGroup
-	mu     sync.Mutex
-	active int // Number of active workers
-	max    int // Maximum number of workers
+	mu       sync.Mutex
+	submitMu sync.Mutex // Serializes enqueue
+	active   int        // Number of active workers
+	max      int        // Maximum number of workers
+
+	clock      Clock
+	jobTimeout time.Duration
+	hooks      Hooks
+	sync       bool
+
+	pending int           // Number of jobs queued or running
+	idle    chan struct{} // Closed when pending drops to zero
 }
 
 // NewWorkerPool initializes and returns a new WorkerPool with the given maxWorkers.
-func NewWorkerPool(maxWorkers int, maxWaitJobs int) *WorkerPool {
-	return &WorkerPool{
+// maxWaitJobs is the queue length; jobs submitted while it is full are dropped.
+func NewWorkerPool(maxWorkers int, maxWaitJobs int, opts ...Option) *WorkerPool {
+	wp := &WorkerPool{
 		taskQueue: make(chan Job, maxWaitJobs), // Buffered channel for jobs
 		max:       maxWorkers,
+		clock:     realClock{},
+		idle:      make(chan struct{}),
+	}
+	close(wp.idle)
+	for _, opt := range opts {
+		opt(wp)
 	}
+	return wp
 }
 
 // Start starts the worker pool with a specified number of workers.
@@ -47,26 +155,158 @@ func (wp *WorkerPool) startWorker() {
 	wp.active++
 	wp.mu.Unlock()
 
+	if wp.sync {
+		return
+	}
+
 	//wp.wg.Add(1)
 	go func() {
 		//defer wp.wg.Done()
 		for job := range wp.taskQueue {
-			//ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			err := job.Run(context.Background())
-			//cancel()
-			if err != nil {
-				logger.Errorf("Error executing job: %v\n", err)
-			}
+			wp.run(job)
+		}
+	}()
+}
+
+// run executes a single job and updates the pending count.
+func (wp *WorkerPool) run(job Job) {
+	ctx, cancel := wp.jobContext()
+	err := job.Run(ctx)
+	cancel()
+	if err != nil {
+		logger.Errorf("Error executing job: %v\n", err)
+	}
+	if wp.hooks.OnDone != nil {
+		wp.hooks.OnDone(job, err)
+	}
+	wp.done()
+}
+
+// jobContext returns the context for one job. With a job timeout it expires like
+// context.WithTimeout, reporting context.DeadlineExceeded, but on the pool's clock.
+func (wp *WorkerPool) jobContext() (context.Context, context.CancelFunc) {
+	if wp.jobTimeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	if _, ok := wp.clock.(realClock); ok {
+		return context.WithTimeout(context.Background(), wp.jobTimeout)
+	}
+	// a custom clock, e.g. a fake one in tests, decides when the deadline is reached
+	parent, cancel := context.WithCancel(context.Background())
+	ctx := &clockDeadlineContext{Context: parent, deadline: wp.clock.Now().Add(wp.jobTimeout)}
+	timeout := wp.clock.After(wp.jobTimeout)
+	go func() {
+		select {
+		case <-timeout:
+			ctx.expire()
+			cancel()
+		case <-parent.Done():
 		}
 	}()
+	return ctx, cancel
+}
+
+// clockDeadlineContext is canceled by jobContext when a Clock reaches the deadline.
+type clockDeadlineContext struct {
+	context.Context
+	deadline time.Time
+
+	mu      sync.Mutex
+	expired bool
+}
+
+func (c *clockDeadlineContext) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+func (c *clockDeadlineContext) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.expired {
+		return context.DeadlineExceeded
+	}
+	return c.Context.Err()
+}
+
+// expire marks the context as timed out unless it was already canceled.
+func (c *clockDeadlineContext) expire() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Context.Err() == nil {
+		c.expired = true
+	}
+}
+
+func (wp *WorkerPool) done() {
+	wp.mu.Lock()
+	wp.pending--
+	if wp.pending == 0 {
+		close(wp.idle)
+	}
+	wp.mu.Unlock()
 }
 
 // Submit submits a job to the worker pool for execution.
 func (wp *WorkerPool) Submit(job Job) {
+	wp.mu.Lock()
+	if wp.pending == 0 {
+		wp.idle = make(chan struct{})
+	}
+	wp.pending++
+	wp.mu.Unlock()
+
+	if wp.enqueue(job) {
+		return
+	}
+	wp.done()
+	if wp.hooks.OnDrop != nil {
+		wp.hooks.OnDrop(job)
+	}
+	fmt.Println("Task queue full, dropping job.")
+}
+
+// enqueue queues job unless the queue is full, calling OnSubmit first: once queued,
+// a worker may finish the job before the send returns.
+func (wp *WorkerPool) enqueue(job Job) bool {
+	wp.submitMu.Lock()
+	defer wp.submitMu.Unlock()
+	// submitters are serialized and workers only take jobs out, so the send cannot block
+	if len(wp.taskQueue) >= cap(wp.taskQueue) {
+		return false
+	}
+	if wp.hooks.OnSubmit != nil {
+		wp.hooks.OnSubmit(job)
+	}
+	wp.taskQueue <- job
+	return true
+}
+
+// RunOne takes one queued job and runs it on the calling goroutine.
+// It reports whether a job was run. It is mainly used together with WithSyncMode.
+func (wp *WorkerPool) RunOne() bool {
 	select {
-	case wp.taskQueue <- job:
+	case job, ok := <-wp.taskQueue:
+		if !ok {
+			return false
+		}
+		wp.run(job)
+		return true
 	default:
-		fmt.Println("Task queue full, dropping job.")
+		return false
+	}
+}
+
+// WaitIdle blocks until every submitted job has finished or ctx is done.
+func (wp *WorkerPool) WaitIdle(ctx context.Context) error {
+	wp.mu.Lock()
+	idle := wp.idle
+	wp.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
@@ -81,7 +321,9 @@ func (wp *WorkerPool) Stats() map[string]int {
 	stats := make(map[string]int)
 	wp.mu.Lock()
 	stats["active_workers"] = wp.active
+	stats["pending_jobs"] = wp.pending
 	wp.mu.Unlock()
+	stats["queued_jobs"] = len(wp.taskQueue)
 	return stats
 }
 
@@ -23,7 +23,11 @@ func TestNewWorkerPool(t *testing.T) {
 	pool.Submit(job)
 	pool.Submit(job)
 
-	time.Sleep(5 * time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := pool.WaitIdle(ctx); err != nil {
+		t.Fatalf("pool did not become idle: %v", err)
+	}
 }
 
 // ExampleJob is an example implementation of the Job interface.
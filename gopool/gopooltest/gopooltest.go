@@ -0,0 +1,145 @@
+// Package gopooltest provides a deterministic harness for testing code built on gopool.WorkerPool.
+package gopooltest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/liuxiaodao666/go-util/gopool"
+)
+
+// JobFunc adapts a function to the gopool.Job interface.
+type JobFunc func(ctx context.Context) error
+
+// Run calls f(ctx).
+func (f JobFunc) Run(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Result is a finished job together with the error it returned.
+type Result struct {
+	Job gopool.Job
+	Err error
+}
+
+// Harness wraps a WorkerPool with a fake clock and records every submitted,
+// dropped and finished job.
+type Harness struct {
+	Pool  *gopool.WorkerPool
+	Clock *FakeClock
+
+	mu        sync.Mutex
+	submitted []gopool.Job
+	dropped   []gopool.Job
+	done      []Result
+}
+
+// New returns a harness in synchronous mode: no worker goroutines are started and
+// queued jobs only run when Step or Drain is called.
+// The harness records jobs through its own hooks, called after those of a
+// gopool.WithHooks option in opts.
+func New(maxWorkers int, maxWaitJobs int, opts ...gopool.Option) *Harness {
+	return newHarness(maxWorkers, maxWaitJobs, true, opts)
+}
+
+// NewAsync returns a harness whose pool runs real worker goroutines.
+// Use WaitIdle to wait for quiescence instead of sleeping.
+func NewAsync(maxWorkers int, maxWaitJobs int, opts ...gopool.Option) *Harness {
+	return newHarness(maxWorkers, maxWaitJobs, false, opts)
+}
+
+func newHarness(maxWorkers int, maxWaitJobs int, syncMode bool, opts []gopool.Option) *Harness {
+	h := &Harness{Clock: NewFakeClock(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))}
+
+	all := []gopool.Option{gopool.WithClock(h.Clock)}
+	all = append(all, opts...)
+	all = append(all, gopool.WithHooks(gopool.Hooks{
+		OnSubmit: h.onSubmit,
+		OnDrop:   h.onDrop,
+		OnDone:   h.onDone,
+	}))
+	if syncMode {
+		all = append(all, gopool.WithSyncMode())
+	}
+	h.Pool = gopool.NewWorkerPool(maxWorkers, maxWaitJobs, all...)
+	return h
+}
+
+func (h *Harness) onSubmit(job gopool.Job) {
+	h.mu.Lock()
+	h.submitted = append(h.submitted, job)
+	h.mu.Unlock()
+}
+
+func (h *Harness) onDrop(job gopool.Job) {
+	h.mu.Lock()
+	h.dropped = append(h.dropped, job)
+	h.mu.Unlock()
+}
+
+func (h *Harness) onDone(job gopool.Job, err error) {
+	h.mu.Lock()
+	h.done = append(h.done, Result{Job: job, Err: err})
+	h.mu.Unlock()
+}
+
+// Step runs the next queued job on the calling goroutine and reports whether there was one.
+func (h *Harness) Step() bool {
+	return h.Pool.RunOne()
+}
+
+// Drain runs queued jobs until the queue is empty and returns how many ran.
+func (h *Harness) Drain() int {
+	n := 0
+	for h.Step() {
+		n++
+	}
+	return n
+}
+
+// WaitIdle waits until every accepted job has finished. The timeout only guards
+// against a hung test; it does not affect ordering.
+func (h *Harness) WaitIdle(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return h.Pool.WaitIdle(ctx)
+}
+
+// Submitted returns the jobs accepted by the pool, in submission order.
+func (h *Harness) Submitted() []gopool.Job {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]gopool.Job(nil), h.submitted...)
+}
+
+// Dropped returns the jobs rejected because the queue was full.
+func (h *Harness) Dropped() []gopool.Job {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]gopool.Job(nil), h.dropped...)
+}
+
+// Done returns the finished jobs, in completion order.
+func (h *Harness) Done() []Result {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]Result(nil), h.done...)
+}
+
+// AssertCounts fails the test if the number of submitted, dropped or finished jobs differs.
+func (h *Harness) AssertCounts(t testing.TB, submitted, dropped, done int) {
+	t.Helper()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.submitted) != submitted {
+		t.Errorf("submitted jobs = %d, want %d", len(h.submitted), submitted)
+	}
+	if len(h.dropped) != dropped {
+		t.Errorf("dropped jobs = %d, want %d", len(h.dropped), dropped)
+	}
+	if len(h.done) != done {
+		t.Errorf("finished jobs = %d, want %d", len(h.done), done)
+	}
+}
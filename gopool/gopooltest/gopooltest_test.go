@@ -0,0 +1,152 @@
+package gopooltest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/liuxiaodao666/go-util/gopool"
+	"github.com/liuxiaodao666/go-util/logger/logtest"
+)
+
+func TestHarnessSyncStep(t *testing.T) {
+	h := New(2, 2)
+	h.Pool.Start(2)
+
+	var ran []int
+	for i := 0; i < 3; i++ {
+		i := i
+		h.Pool.Submit(JobFunc(func(ctx context.Context) error {
+			ran = append(ran, i)
+			return nil
+		}))
+	}
+	h.AssertCounts(t, 2, 1, 0)
+
+	if !h.Step() {
+		t.Fatal("Step() = false, want a queued job")
+	}
+	h.AssertCounts(t, 2, 1, 1)
+
+	if n := h.Drain(); n != 1 {
+		t.Fatalf("Drain() = %d, want 1", n)
+	}
+	if h.Step() {
+		t.Fatal("Step() = true on an empty queue")
+	}
+	if len(ran) != 2 || ran[0] != 0 || ran[1] != 1 {
+		t.Fatalf("ran = %v, want [0 1]", ran)
+	}
+	if err := h.WaitIdle(time.Second); err != nil {
+		t.Fatalf("WaitIdle() = %v", err)
+	}
+}
+
+func TestHarnessAsyncWaitIdle(t *testing.T) {
+	h := NewAsync(4, 16)
+	h.Pool.Start(4)
+
+	errBoom := errors.New("boom")
+	for i := 0; i < 10; i++ {
+		i := i
+		h.Pool.Submit(JobFunc(func(ctx context.Context) error {
+			if i%2 == 0 {
+				return errBoom
+			}
+			return nil
+		}))
+	}
+	if err := h.WaitIdle(10 * time.Second); err != nil {
+		t.Fatalf("WaitIdle() = %v", err)
+	}
+	h.AssertCounts(t, 10, 0, 10)
+
+	failed := 0
+	for _, r := range h.Done() {
+		if r.Err != nil {
+			failed++
+		}
+	}
+	if failed != 5 {
+		t.Fatalf("failed jobs = %d, want 5", failed)
+	}
+}
+
+func TestFakeClockJobTimeout(t *testing.T) {
+	h := NewAsync(1, 1, gopool.WithJobTimeout(time.Minute))
+	h.Pool.Start(1)
+
+	h.Pool.Submit(JobFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	h.Clock.BlockUntil(1)
+	h.Clock.Advance(30 * time.Second)
+	if h.Clock.Waiters() != 1 {
+		t.Fatal("timer fired before the deadline")
+	}
+	h.Clock.Advance(30 * time.Second)
+
+	if err := h.WaitIdle(10 * time.Second); err != nil {
+		t.Fatalf("WaitIdle() = %v", err)
+	}
+	done := h.Done()
+	if len(done) != 1 || !errors.Is(done[0].Err, context.DeadlineExceeded) {
+		t.Fatalf("Done() = %+v, want one timed out job", done)
+	}
+}
+
+func TestJobCanceledAfterFinish(t *testing.T) {
+	h := New(1, 1, gopool.WithJobTimeout(time.Minute))
+	h.Pool.Start(1)
+
+	var jobCtx context.Context
+	h.Pool.Submit(JobFunc(func(ctx context.Context) error {
+		jobCtx = ctx
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("job context has no deadline")
+		}
+		return nil
+	}))
+	h.Drain()
+	h.Clock.Advance(time.Minute)
+	if err := jobCtx.Err(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ctx.Err() after the job = %v, want context.Canceled", err)
+	}
+}
+
+func TestUserHooksChained(t *testing.T) {
+	var events []string
+	h := NewAsync(1, 1, gopool.WithHooks(gopool.Hooks{
+		OnSubmit: func(gopool.Job) { events = append(events, "submit") },
+		OnDone:   func(gopool.Job, error) { events = append(events, "done") },
+	}))
+	h.Pool.Start(1)
+
+	for i := 0; i < 20; i++ {
+		events = events[:0]
+		h.Pool.Submit(JobFunc(func(ctx context.Context) error { return nil }))
+		if err := h.WaitIdle(10 * time.Second); err != nil {
+			t.Fatalf("WaitIdle() = %v", err)
+		}
+		if len(events) != 2 || events[0] != "submit" || events[1] != "done" {
+			t.Fatalf("events = %v, want [submit done]", events)
+		}
+	}
+	h.AssertCounts(t, 20, 0, 20)
+}
+
+func TestJobErrorLogged(t *testing.T) {
+	logs := logtest.Observe(t)
+	h := New(1, 1)
+	h.Pool.Start(1)
+
+	h.Pool.Submit(JobFunc(func(ctx context.Context) error {
+		return errors.New("disk full")
+	}))
+	h.Drain()
+
+	logs.AssertLogged(t, "error", "Error executing job: disk full")
+}
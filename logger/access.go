@@ -0,0 +1,281 @@
+package logger
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Access log formats.
+const (
+	AccessFormatJSON     = "json"
+	AccessFormatCombined = "combined"
+)
+
+// Access log field names, usable in AccessLogConfig.Fields.
+const (
+	AccessMethod     = "method"
+	AccessPath       = "path"
+	AccessQuery      = "query"
+	AccessProto      = "proto"
+	AccessHost       = "host"
+	AccessStatus     = "status"
+	AccessBytes      = "bytes"
+	AccessLatency    = "latency"
+	AccessRemoteAddr = "remote_addr"
+	AccessRequestID  = "request_id"
+	AccessUserAgent  = "user_agent"
+	AccessReferer    = "referer"
+)
+
+var defaultAccessFields = []string{
+	AccessMethod, AccessPath, AccessStatus, AccessBytes,
+	AccessLatency, AccessRemoteAddr, AccessRequestID,
+}
+
+// AccessLogConfig configures AccessLog.
+type AccessLogConfig struct {
+	// Format is "json" (default), one entry with a field per value, or "combined",
+	// the Apache combined log line as the message.
+	Format string
+	// Fields selects the fields of the json format. Default method, path, status,
+	// bytes, latency, remote_addr and request_id.
+	Fields []string
+	// SkipPaths are paths that are not logged. A trailing "*" matches a prefix,
+	// e.g. "/static/*".
+	SkipPaths []string
+	// SampleEvery logs one in SampleEvery successful requests (status < 400).
+	// Errors are always logged. 0 or 1 logs every request.
+	SampleEvery int
+	// Suppressed, if set, is incremented atomically for every request dropped
+	// by SampleEvery. It is separate from the logger's Suppressed count.
+	Suppressed *uint64
+	// RequestIDHeader is read for the request ID and set on the response.
+	// A random ID is generated when the request has none. Default "X-Request-ID".
+	RequestIDHeader string
+	// Logger receives the entries. Default Module("access").
+	Logger *Logger
+}
+
+// AccessLog returns a middleware that logs every request handled by next.
+// Successful requests are logged at info, 4xx at warn and 5xx at error.
+// The request ID is stored in the request context under RequestIDKey,
+// so InfoCtx and friends in next log it too.
+// A panic in next is logged as a 500 with a "panic" field and then re-raised.
+func AccessLog(next http.Handler, cfg AccessLogConfig) http.Handler {
+	if cfg.Format == "" {
+		cfg.Format = AccessFormatJSON
+	}
+	if len(cfg.Fields) == 0 {
+		cfg.Fields = defaultAccessFields
+	}
+	if cfg.RequestIDHeader == "" {
+		cfg.RequestIDHeader = "X-Request-ID"
+	}
+	l := cfg.Logger
+	if l == nil {
+		l = Module("access")
+	}
+
+	var seen uint64
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(cfg.RequestIDHeader)
+		if id == "" {
+			id = newRequestID()
+		}
+		w.Header().Set(cfg.RequestIDHeader, id)
+		r = r.WithContext(context.WithValue(r.Context(), RequestIDKey, id))
+
+		if skipPath(cfg.SkipPaths, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		rw := &responseRecorder{ResponseWriter: w}
+		defer func() {
+			// a panicking handler is logged as a 500, then the panic goes on to
+			// net/http (or an outer recovery middleware)
+			p := recover()
+			if p != nil && rw.status < 500 {
+				rw.status = http.StatusInternalServerError
+			}
+			latency := time.Since(start)
+			if rw.status == 0 {
+				rw.status = http.StatusOK
+			}
+
+			if rw.status < 400 && cfg.SampleEvery > 1 {
+				if (atomic.AddUint64(&seen, 1)-1)%uint64(cfg.SampleEvery) != 0 {
+					if cfg.Suppressed != nil {
+						atomic.AddUint64(cfg.Suppressed, 1)
+					}
+					return
+				}
+			}
+
+			var msg string
+			var fields []Field
+			if cfg.Format == AccessFormatCombined {
+				msg = combinedLine(r, rw, start)
+			} else {
+				msg = "access"
+				fields = accessFields(cfg.Fields, r, rw, latency, id)
+			}
+			if p != nil {
+				fields = append(fields, Any("panic", p))
+			}
+			switch {
+			case rw.status >= 500:
+				l.Error(msg, fields...)
+			case rw.status >= 400:
+				l.Warn(msg, fields...)
+			default:
+				l.Info(msg, fields...)
+			}
+			if p != nil {
+				panic(p)
+			}
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+func skipPath(skip []string, path string) bool {
+	for _, p := range skip {
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(path, strings.TrimSuffix(p, "*")) {
+				return true
+			}
+		} else if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+func accessFields(names []string, r *http.Request, rw *responseRecorder, latency time.Duration, id string) []Field {
+	fields := make([]Field, 0, len(names))
+	for _, name := range names {
+		switch name {
+		case AccessMethod:
+			fields = append(fields, String(name, r.Method))
+		case AccessPath:
+			fields = append(fields, String(name, r.URL.Path))
+		case AccessQuery:
+			fields = append(fields, String(name, r.URL.RawQuery))
+		case AccessProto:
+			fields = append(fields, String(name, r.Proto))
+		case AccessHost:
+			fields = append(fields, String(name, r.Host))
+		case AccessStatus:
+			fields = append(fields, Int(name, rw.status))
+		case AccessBytes:
+			fields = append(fields, Int64(name, rw.bytes))
+		case AccessLatency:
+			fields = append(fields, Duration(name, latency))
+		case AccessRemoteAddr:
+			fields = append(fields, String(name, remoteHost(r)))
+		case AccessRequestID:
+			fields = append(fields, String(name, id))
+		case AccessUserAgent:
+			fields = append(fields, String(name, r.UserAgent()))
+		case AccessReferer:
+			fields = append(fields, String(name, r.Referer()))
+		}
+	}
+	return fields
+}
+
+// combinedLine formats the Apache combined log format:
+// host ident user [time] "request" status bytes "referer" "user-agent".
+func combinedLine(r *http.Request, rw *responseRecorder, start time.Time) string {
+	user := "-"
+	if u, _, ok := r.BasicAuth(); ok && u != "" {
+		user = u
+	}
+	size := "-"
+	if rw.bytes > 0 {
+		size = strconv.FormatInt(rw.bytes, 10)
+	}
+	return fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s %q %q",
+		remoteHost(r), user, start.Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method, r.URL.RequestURI(), r.Proto, rw.status, size,
+		orDash(r.Referer()), orDash(r.UserAgent()))
+}
+
+func remoteHost(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func newRequestID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// responseRecorder captures the status code and the body size.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (rw *responseRecorder) WriteHeader(code int) {
+	if rw.status == 0 {
+		rw.status = code
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *responseRecorder) Write(p []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	n, err := rw.ResponseWriter.Write(p)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+func (rw *responseRecorder) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rw *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("logger: response writer does not support hijacking")
+	}
+	if rw.status == 0 {
+		rw.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the original writer.
+func (rw *responseRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
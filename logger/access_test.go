@@ -0,0 +1,162 @@
+package logger_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/liuxiaodao666/go-util/logger"
+	"github.com/liuxiaodao666/go-util/logger/logtest"
+)
+
+func accessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.InfoCtx(r.Context(), "handling")
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+		case "/fail":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.Write([]byte("hello"))
+		}
+	})
+}
+
+func serve(h http.Handler, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	logs := logtest.Observe(t)
+	h := logger.AccessLog(accessHandler(), logger.AccessLogConfig{})
+
+	rec := serve(h, "/hello?x=1", http.Header{"X-Request-Id": {"req-1"}})
+	if got := rec.Header().Get("X-Request-ID"); got != "req-1" {
+		t.Errorf("response request id = %q, want req-1", got)
+	}
+	logs.AssertLogged(t, "info", "access",
+		logger.String("method", "GET"), logger.String("path", "/hello"),
+		logger.Int("status", 200), logger.Int64("bytes", 5),
+		logger.String("remote_addr", "192.0.2.1"), logger.String("request_id", "req-1"))
+	logs.AssertLogged(t, "info", "handling", logger.String("request_id", "req-1"))
+
+	serve(h, "/missing", nil)
+	logs.AssertLogged(t, "warn", "access", logger.Int("status", 404))
+	serve(h, "/fail", nil)
+	logs.AssertLogged(t, "error", "access", logger.Int("status", 500))
+
+	e := logs.Message("access")[0]
+	if e.LoggerName != "access" {
+		t.Errorf("logger name = %q, want access", e.LoggerName)
+	}
+	if _, ok := e.ContextMap()["latency"]; !ok {
+		t.Error("latency field missing")
+	}
+}
+
+func TestAccessLogGeneratesRequestID(t *testing.T) {
+	logs := logtest.Observe(t)
+	h := logger.AccessLog(accessHandler(), logger.AccessLogConfig{RequestIDHeader: "X-Trace"})
+
+	rec := serve(h, "/", nil)
+	id := rec.Header().Get("X-Trace")
+	if id == "" {
+		t.Fatal("no request id generated")
+	}
+	logs.AssertLogged(t, "info", "access", logger.String("request_id", id))
+}
+
+func TestAccessLogCombined(t *testing.T) {
+	logs := logtest.Observe(t)
+	h := logger.AccessLog(accessHandler(), logger.AccessLogConfig{Format: logger.AccessFormatCombined})
+
+	serve(h, "/hello?x=1", http.Header{"User-Agent": {"curl/7.0"}})
+	entries := logs.Contains("GET /hello?x=1 HTTP/1.1")
+	if len(entries) != 1 {
+		t.Fatalf("combined entries = %d, want 1: %+v", len(entries), logs.All())
+	}
+	msg := entries[0].Message
+	if !strings.HasPrefix(msg, "192.0.2.1 - - [") || !strings.HasSuffix(msg, `" 200 5 "-" "curl/7.0"`) {
+		t.Errorf("combined line = %q", msg)
+	}
+}
+
+func TestAccessLogFieldsAndSkip(t *testing.T) {
+	logs := logtest.Observe(t)
+	h := logger.AccessLog(accessHandler(), logger.AccessLogConfig{
+		Fields:    []string{logger.AccessPath, logger.AccessQuery},
+		SkipPaths: []string{"/healthz", "/static/*"},
+	})
+
+	serve(h, "/healthz", nil)
+	serve(h, "/static/app.js", nil)
+	serve(h, "/api?page=2", nil)
+
+	entries := logs.Message("access")
+	if len(entries) != 1 {
+		t.Fatalf("access entries = %d, want 1", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if len(fields) != 2 || fields["path"] != "/api" || fields["query"] != "page=2" {
+		t.Errorf("fields = %v, want path and query only", fields)
+	}
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	logs := logtest.Observe(t)
+	var dropped uint64
+	h := logger.AccessLog(accessHandler(), logger.AccessLogConfig{SampleEvery: 5, Suppressed: &dropped})
+
+	before := logger.Suppressed()
+	for i := 0; i < 10; i++ {
+		serve(h, "/ok", nil)
+		serve(h, "/fail", nil)
+	}
+	if dropped != 8 {
+		t.Errorf("suppressed access requests = %d, want 8", dropped)
+	}
+	if n := logger.Suppressed() - before; n != 0 {
+		t.Errorf("logger.Suppressed() grew by %d, want 0", n)
+	}
+	if n := len(logs.Level("info")) - len(logs.Message("handling")); n != 2 {
+		t.Errorf("sampled successful entries = %d, want 2", n)
+	}
+	if n := len(logs.Level("error")); n != 10 {
+		t.Errorf("error entries = %d, want 10", n)
+	}
+}
+
+func TestAccessLogPanic(t *testing.T) {
+	logs := logtest.Observe(t)
+	h := logger.AccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), logger.AccessLogConfig{})
+
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Errorf("recovered %v, want the handler's panic", p)
+			}
+		}()
+		serve(h, "/panic", nil)
+	}()
+	logs.AssertLogged(t, "error", "access",
+		logger.String("path", "/panic"), logger.Int("status", 500), logger.String("panic", "boom"))
+}
+
+func TestAccessLogResolvesLoggerPerRequest(t *testing.T) {
+	// built before the observer is installed, as in a server set up before Init
+	h := logger.AccessLog(accessHandler(), logger.AccessLogConfig{})
+	logs := logtest.Observe(t)
+
+	serve(h, "/late", nil)
+	logs.AssertLogged(t, "info", "access", logger.String("path", "/late"))
+}
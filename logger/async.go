@@ -0,0 +1,223 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Async overflow policies.
+const (
+	PolicyDrop  = "drop"
+	PolicyBlock = "block"
+)
+
+// AsyncConfig enables buffered, asynchronous writes to the outputs.
+type AsyncConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled" toml:"enabled"`
+	// BufferSize is the number of entries the ring buffer holds. Default 8192.
+	BufferSize int `json:"buffer_size" yaml:"buffer_size" toml:"buffer_size"`
+	// FlushInterval is how often the buffer is flushed, e.g. "1s". Default 1s.
+	FlushInterval string `json:"flush_interval" yaml:"flush_interval" toml:"flush_interval"`
+	// FlushSize flushes early once this many bytes are buffered. Default 256KB.
+	FlushSize int `json:"flush_size" yaml:"flush_size" toml:"flush_size"`
+	// Policy is what Write does when the buffer is full: "drop" (default) or "block".
+	Policy string `json:"policy" yaml:"policy" toml:"policy"`
+}
+
+func (c AsyncConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.FlushInterval != "" {
+		if d, err := time.ParseDuration(c.FlushInterval); err != nil || d <= 0 {
+			return fmt.Errorf("logger: invalid async flush interval %q", c.FlushInterval)
+		}
+	}
+	switch c.Policy {
+	case "", PolicyDrop, PolicyBlock:
+	default:
+		return fmt.Errorf("logger: unknown async policy %q", c.Policy)
+	}
+	return nil
+}
+
+var errAsyncClosed = errors.New("logger: async writer closed")
+
+// AsyncWriter buffers entries in a bounded ring and writes them to the wrapped
+// WriteSyncer from a background goroutine, in batches.
+type AsyncWriter struct {
+	ws            zapcore.WriteSyncer
+	flushInterval time.Duration
+	flushSize     int
+	block         bool
+
+	mu       sync.Mutex
+	notFull  *sync.Cond
+	ring     [][]byte
+	head     int
+	count    int
+	buffered int
+	closed   bool
+
+	dropped uint64
+	written uint64
+
+	kick    chan struct{}
+	syncReq chan chan error
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewAsyncWriter starts an AsyncWriter around ws. Zero values in cfg use the defaults.
+func NewAsyncWriter(ws zapcore.WriteSyncer, cfg AsyncConfig) *AsyncWriter {
+	w := &AsyncWriter{
+		ws:            ws,
+		flushInterval: time.Second,
+		flushSize:     256 * 1024,
+		block:         cfg.Policy == PolicyBlock,
+		kick:          make(chan struct{}, 1),
+		syncReq:       make(chan chan error),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	size := cfg.BufferSize
+	if size <= 0 {
+		size = 8192
+	}
+	w.ring = make([][]byte, size)
+	if d, err := time.ParseDuration(cfg.FlushInterval); err == nil && d > 0 {
+		w.flushInterval = d
+	}
+	if cfg.FlushSize > 0 {
+		w.flushSize = cfg.FlushSize
+	}
+	w.notFull = sync.NewCond(&w.mu)
+
+	go w.run()
+	return w
+}
+
+// Write copies p into the ring buffer. When the buffer is full it either drops p
+// or blocks until the flusher makes room, depending on the policy.
+func (w *AsyncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	for w.count == len(w.ring) && w.block && !w.closed {
+		w.notFull.Wait()
+	}
+	if w.closed {
+		w.mu.Unlock()
+		return 0, errAsyncClosed
+	}
+	if w.count == len(w.ring) {
+		w.mu.Unlock()
+		atomic.AddUint64(&w.dropped, 1)
+		return len(p), nil
+	}
+
+	w.ring[(w.head+w.count)%len(w.ring)] = append([]byte(nil), p...)
+	w.count++
+	w.buffered += len(p)
+	full := w.buffered >= w.flushSize || w.count == len(w.ring)
+	w.mu.Unlock()
+
+	if full {
+		select {
+		case w.kick <- struct{}{}:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+// Sync flushes the buffer and syncs the wrapped writer.
+func (w *AsyncWriter) Sync() error {
+	reply := make(chan error, 1)
+	select {
+	case w.syncReq <- reply:
+		return <-reply
+	case <-w.done:
+		return nil
+	}
+}
+
+// Close flushes the remaining entries and stops the background goroutine.
+// It does not close the wrapped writer.
+func (w *AsyncWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.notFull.Broadcast()
+	w.mu.Unlock()
+
+	close(w.stop)
+	<-w.done
+	return w.ws.Sync()
+}
+
+// Dropped returns the number of entries discarded because the buffer was full.
+func (w *AsyncWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&w.dropped)
+}
+
+// Written returns the number of entries handed to the wrapped writer.
+func (w *AsyncWriter) Written() uint64 {
+	return atomic.LoadUint64(&w.written)
+}
+
+func (w *AsyncWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	var batch bytes.Buffer
+	for {
+		select {
+		case <-ticker.C:
+			w.flush(&batch)
+		case <-w.kick:
+			w.flush(&batch)
+		case reply := <-w.syncReq:
+			err := w.flush(&batch)
+			if syncErr := w.ws.Sync(); err == nil {
+				err = syncErr
+			}
+			reply <- err
+		case <-w.stop:
+			w.flush(&batch)
+			return
+		}
+	}
+}
+
+// flush moves every buffered entry into batch and writes it with a single call.
+func (w *AsyncWriter) flush(batch *bytes.Buffer) error {
+	w.mu.Lock()
+	n := w.count
+	for i := 0; i < n; i++ {
+		idx := (w.head + i) % len(w.ring)
+		batch.Write(w.ring[idx])
+		w.ring[idx] = nil
+	}
+	w.head = (w.head + n) % len(w.ring)
+	w.count = 0
+	w.buffered = 0
+	w.notFull.Broadcast()
+	w.mu.Unlock()
+
+	if n == 0 {
+		return nil
+	}
+	_, err := w.ws.Write(batch.Bytes())
+	batch.Reset()
+	atomic.AddUint64(&w.written, uint64(n))
+	return err
+}
@@ -0,0 +1,143 @@
+package logger
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+type lockedBuffer struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	writes int
+	gate   chan struct{}
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	if b.gate != nil {
+		<-b.gate
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.writes++
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) Sync() error { return nil }
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestAsyncWriterBatchesAndSyncs(t *testing.T) {
+	dst := &lockedBuffer{}
+	w := NewAsyncWriter(dst, AsyncConfig{FlushInterval: "1h"})
+	for i := 0; i < 3; i++ {
+		w.Write([]byte("line\n"))
+	}
+	if err := w.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if dst.String() != "line\nline\nline\n" || dst.writes != 1 {
+		t.Fatalf("got %q in %d writes, want 3 lines in 1 write", dst.String(), dst.writes)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("late\n")); err == nil {
+		t.Fatal("Write after Close succeeded")
+	}
+}
+
+func TestAsyncWriterDropPolicy(t *testing.T) {
+	dst := &lockedBuffer{gate: make(chan struct{})}
+	w := NewAsyncWriter(dst, AsyncConfig{BufferSize: 2, FlushInterval: "1h", Policy: PolicyDrop})
+
+	// The first full buffer kicks the flusher, which then blocks on the gate
+	// with both entries taken out of the ring.
+	w.Write([]byte("a\n"))
+	w.Write([]byte("b\n"))
+	syncDone := make(chan struct{})
+	go func() {
+		w.Sync()
+		close(syncDone)
+	}()
+	w.Write([]byte("c\n"))
+	w.Write([]byte("d\n"))
+	w.Write([]byte("e\n"))
+
+	close(dst.gate)
+	<-syncDone
+	w.Close()
+
+	if got := w.Dropped() + w.Written(); got != 5 {
+		t.Fatalf("dropped %d + written %d != 5", w.Dropped(), w.Written())
+	}
+	if w.Dropped() == 0 {
+		t.Fatalf("no entries dropped, output %q", dst.String())
+	}
+}
+
+func TestAsyncWriterBlockPolicy(t *testing.T) {
+	dst := &lockedBuffer{}
+	w := NewAsyncWriter(dst, AsyncConfig{BufferSize: 1, FlushInterval: "1h", Policy: PolicyBlock})
+	for i := 0; i < 100; i++ {
+		w.Write([]byte("x"))
+	}
+	w.Close()
+	if w.Dropped() != 0 || len(dst.String()) != 100 {
+		t.Fatalf("dropped %d, wrote %d bytes, want 0 and 100", w.Dropped(), len(dst.String()))
+	}
+}
+
+func TestInitAsync(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "async.log")
+	cfg := DefaultConfig()
+	cfg.Filename = path
+	cfg.Async = AsyncConfig{Enabled: true, FlushInterval: "1h"}
+	if err := Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer Init(DefaultConfig())
+
+	Info("buffered")
+	if err := Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if !bytes.Contains(data, []byte("buffered")) {
+		t.Fatalf("file = %q, want the buffered entry after Close", data)
+	}
+}
+
+func benchmarkLogger(b *testing.B, async bool) {
+	var ws zapcore.WriteSyncer = zapcore.AddSync(&lumberjack.Logger{
+		Filename: filepath.Join(b.TempDir(), "bench.log"),
+		MaxSize:  100,
+	})
+	if async {
+		aw := NewAsyncWriter(ws, AsyncConfig{Policy: PolicyBlock})
+		defer aw.Close()
+		ws = aw
+	}
+	l := zap.New(zapcore.NewCore(getEncoder("console", false), ws, zapcore.InfoLevel))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			l.Info("benchmark entry", zap.Int("n", 42), zap.String("module", "bench"))
+		}
+	})
+}
+
+func BenchmarkSyncWrite(b *testing.B)  { benchmarkLogger(b, false) }
+func BenchmarkAsyncWrite(b *testing.B) { benchmarkLogger(b, true) }
@@ -0,0 +1,177 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/liuxiaodao666/go-util/logger/logfile"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// EnvAuditKey is where logtool verify reads the audit HMAC key by default.
+const EnvAuditKey = "LOG_AUDIT_KEY"
+
+// AuditConfig configures an AuditLogger.
+type AuditConfig struct {
+	// Filename is the audit log path, e.g. "./log/audit.log".
+	Filename string `json:"filename" yaml:"filename" toml:"filename"`
+	// Rotation controls how the file is rotated. MaxAge and MaxBackups are ignored:
+	// audit archives are never deleted by the logger.
+	Rotation RotationConfig `json:"rotation" yaml:"rotation" toml:"rotation"`
+	// Key turns the entry hashes into HMAC-SHA256, so the chain cannot be rebuilt
+	// without it. Keep it out of the config file, e.g. in LOG_AUDIT_KEY.
+	Key string `json:"-" yaml:"-" toml:"-"`
+}
+
+// AuditLogger writes tamper-evident JSON entries: each carries a sequence number and
+// the hash of the entry before it, see logfile.VerifyAudit. It is independent of the
+// global logger; levels, sampling, redaction and hooks do not apply.
+type AuditLogger struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+	key    []byte
+	seq    uint64
+	prev   string
+	now    func() time.Time
+	closed bool
+}
+
+var errAuditClosed = errors.New("logger: audit logger closed")
+
+// NewAuditLogger opens the audit log and continues the chain of the existing files.
+func NewAuditLogger(cfg AuditConfig) (*AuditLogger, error) {
+	if cfg.Filename == "" {
+		return nil, errors.New("logger: audit filename is empty")
+	}
+	rc := cfg.Rotation
+	rc.MaxAge, rc.MaxBackups = 0, 0
+	name := cfg.Filename
+	if rc.Interval != "" && rc.Pattern != "" {
+		name = rc.Pattern
+	}
+
+	last, ok, err := logfile.LastAuditRecord(name)
+	var torn *logfile.TornAuditError
+	if errors.As(err, &torn) {
+		// a crash cut the last write short: continue after the last complete entry
+		fmt.Fprintf(os.Stderr, "logger: resume audit chain: %v, skipped\n", torn)
+		err = nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("logger: resume audit chain: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.Filename), 0755); err != nil {
+		return nil, err
+	}
+
+	l := &AuditLogger{key: []byte(cfg.Key), prev: logfile.GenesisHash, now: time.Now}
+	if ok {
+		l.seq, l.prev = last.Seq, last.Hash
+	}
+	if rc.Interval != "" {
+		r, err := NewTimeRotator(cfg.Filename, rc)
+		if err != nil {
+			return nil, err
+		}
+		l.w, l.closer = r, r
+	} else {
+		lj := &lumberjack.Logger{
+			Filename:  cfg.Filename,
+			MaxSize:   rc.MaxSize,
+			LocalTime: rc.LocalTime,
+			Compress:  rc.Compress,
+		}
+		l.w, l.closer = lj, lj
+	}
+	if torn != nil {
+		// end the partial line so the next entry starts on its own
+		if _, err := l.w.Write([]byte("\n")); err != nil {
+			l.closer.Close()
+			return nil, fmt.Errorf("logger: write audit entry: %w", err)
+		}
+	}
+	return l, nil
+}
+
+// auditBody is the hashed part of an entry; the field order is part of the format.
+type auditBody struct {
+	Seq    uint64                 `json:"seq"`
+	Time   string                 `json:"ts"`
+	Event  string                 `json:"event"`
+	Fields map[string]interface{} `json:"fields,omitempty"`
+	Prev   string                 `json:"prev"`
+}
+
+// Log writes one audit entry. Unlike the other log calls it returns the write error,
+// and the entry is written before Log returns.
+func (l *AuditLogger) Log(event string, fields ...Field) error {
+	var m map[string]interface{}
+	if len(fields) > 0 {
+		enc := zapcore.NewMapObjectEncoder()
+		for _, f := range fields {
+			f.AddTo(enc)
+		}
+		m = enc.Fields
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return errAuditClosed
+	}
+
+	body, err := json.Marshal(auditBody{
+		Seq:    l.seq + 1,
+		Time:   l.now().UTC().Format(time.RFC3339Nano),
+		Event:  event,
+		Fields: m,
+		Prev:   l.prev,
+	})
+	if err != nil {
+		return fmt.Errorf("logger: audit entry: %w", err)
+	}
+	hash := logfile.AuditHash(l.key, body)
+	line := make([]byte, 0, len(body)+len(hash)+12)
+	line = append(line, body[:len(body)-1]...)
+	line = append(line, `,"hash":"`...)
+	line = append(line, hash...)
+	line = append(line, "\"}\n"...)
+
+	if _, err := l.w.Write(line); err != nil {
+		return fmt.Errorf("logger: write audit entry: %w", err)
+	}
+	l.seq++
+	l.prev = hash
+	return nil
+}
+
+// Logw is like Log but takes alternating keys and values.
+func (l *AuditLogger) Logw(event string, keysAndValues ...interface{}) error {
+	return l.Log(event, sweeten(keysAndValues)...)
+}
+
+// Seq returns the sequence number of the last entry written.
+func (l *AuditLogger) Seq() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.seq
+}
+
+// Close closes the audit file. Later calls to Log fail.
+func (l *AuditLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	return l.closer.Close()
+}
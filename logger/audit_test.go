@@ -0,0 +1,194 @@
+package logger_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/liuxiaodao666/go-util/logger"
+	"github.com/liuxiaodao666/go-util/logger/logfile"
+)
+
+func writeAudit(t *testing.T, cfg logger.AuditConfig, events ...string) {
+	t.Helper()
+	l, err := logger.NewAuditLogger(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range events {
+		if err := l.Log(e, logger.String("user", "alice"), logger.Int("n", len(e))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Log("late"); err == nil {
+		t.Error("Log after Close succeeded")
+	}
+}
+
+func verifyAudit(t *testing.T, name, key string) *logfile.AuditReport {
+	t.Helper()
+	rep, err := logfile.VerifyAudit(name, []byte(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rep
+}
+
+// rotateAudit moves the audit file away like lumberjack does, compressing it.
+func rotateAudit(t *testing.T, name, suffix string) {
+	t.Helper()
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(b)
+	gz.Close()
+	archive := strings.TrimSuffix(name, ".log") + "-" + suffix + ".log.gz"
+	if err := ioutil.WriteFile(archive, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(name)
+}
+
+func TestAuditChainAcrossRotation(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "audit.log")
+	cfg := logger.AuditConfig{Filename: name, Key: "secret"}
+
+	writeAudit(t, cfg, "login", "grant")
+	rotateAudit(t, name, "2024-01-01T00-00-00.000")
+	writeAudit(t, cfg, "revoke")
+
+	rep := verifyAudit(t, name, "secret")
+	if !rep.OK() || rep.Files != 2 || rep.Entries != 3 || rep.LastSeq != 3 {
+		t.Fatalf("report = %+v", rep)
+	}
+
+	rec, ok, err := logfile.LastAuditRecord(name)
+	if err != nil || !ok || rec.Event != "revoke" || rec.Fields["user"] != "alice" || rec.Hash != rep.LastHash {
+		t.Errorf("last record = %+v, %v, %v", rec, ok, err)
+	}
+	if rep := verifyAudit(t, name, "wrong"); rep.OK() {
+		t.Error("chain verified with the wrong key")
+	}
+}
+
+func readLines(t *testing.T, name string) []string {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.SplitAfter(strings.TrimSuffix(string(b), "\n"), "\n")
+}
+
+func TestAuditTamper(t *testing.T) {
+	cases := map[string]struct {
+		tamper func(lines []string) []string
+		reason string
+	}{
+		"edit": {func(l []string) []string {
+			l[1] = strings.Replace(l[1], "alice", "mallory", 1)
+			return l
+		}, "hash mismatch"},
+		"delete": {func(l []string) []string {
+			return append(l[:1], l[2:]...)
+		}, "entries 2-2 are missing"},
+		"reorder": {func(l []string) []string {
+			l[1], l[2] = l[2], l[1]
+			return l
+		}, "missing"},
+		"delete first": {func(l []string) []string {
+			return l[1:]
+		}, "chain starts at seq 2"},
+		"garbage": {func(l []string) []string {
+			return append(l, "not json\n")
+		}, "unparsable"},
+	}
+	for label, tc := range cases {
+		t.Run(label, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "audit.log")
+			writeAudit(t, logger.AuditConfig{Filename: name}, "a", "b", "c", "d")
+			if rep := verifyAudit(t, name, ""); !rep.OK() {
+				t.Fatalf("untouched chain: %+v", rep.Problems)
+			}
+
+			lines := tc.tamper(readLines(t, name))
+			ioutil.WriteFile(name, []byte(strings.Join(lines, "")), 0644)
+
+			rep := verifyAudit(t, name, "")
+			if rep.OK() {
+				t.Fatal("tampering not detected")
+			}
+			if !strings.Contains(rep.Problems[0].Reason, tc.reason) {
+				t.Errorf("problems = %v, want %q", rep.Problems, tc.reason)
+			}
+		})
+	}
+}
+
+func TestAuditMissingArchive(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "audit.log")
+	cfg := logger.AuditConfig{Filename: name}
+
+	writeAudit(t, cfg, "a")
+	rotateAudit(t, name, "2024-01-01T00-00-00.000")
+	writeAudit(t, cfg, "b")
+	rotateAudit(t, name, "2024-01-02T00-00-00.000")
+	writeAudit(t, cfg, "c")
+	os.Remove(filepath.Join(dir, "audit-2024-01-02T00-00-00.000.log.gz"))
+
+	rep := verifyAudit(t, name, "")
+	if rep.OK() || rep.Problems[0].Reason != "entries 2-2 are missing" {
+		t.Errorf("problems = %v", rep.Problems)
+	}
+}
+
+func TestAuditResumeAfterTornEntry(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "audit.log")
+	cfg := logger.AuditConfig{Filename: name, Key: "secret"}
+
+	writeAudit(t, cfg, "a", "b")
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"seq":3,"ts":"2024-`)
+	f.Close()
+
+	rec, ok, err := logfile.LastAuditRecord(name)
+	var torn *logfile.TornAuditError
+	if !errors.As(err, &torn) || !ok || rec.Seq != 2 {
+		t.Fatalf("last record = %+v, %v, %v; want seq 2 and a torn entry", rec, ok, err)
+	}
+
+	writeAudit(t, cfg, "c")
+	rep := verifyAudit(t, name, "secret")
+	if rep.Entries != 3 || rep.LastSeq != 3 || len(rep.Problems) != 1 ||
+		!strings.HasPrefix(rep.Problems[0].Reason, "unparsable entry") {
+		t.Errorf("report = %+v", rep)
+	}
+}
+
+func TestAuditResumeIgnoresSiblings(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "audit.log")
+	writeAudit(t, logger.AuditConfig{Filename: name}, "a")
+	// another audit log sharing the prefix, written later
+	writeAudit(t, logger.AuditConfig{Filename: filepath.Join(dir, "audit-admin.log")}, "x", "y", "z")
+
+	writeAudit(t, logger.AuditConfig{Filename: name}, "b")
+	rep := verifyAudit(t, name, "")
+	if !rep.OK() || rep.Files != 1 || rep.LastSeq != 2 {
+		t.Errorf("report = %+v", rep)
+	}
+}
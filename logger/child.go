@@ -0,0 +1,129 @@
+package logger
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Logger is a child logger carrying fields added by With.
+// It writes through the same core as the package-level functions.
+type Logger struct {
+	zl *zap.Logger
+
+	// a Module logger resolves the global logger when it logs, so one created at
+	// import time writes to the outputs of a later Init; zl is nil then
+	module string
+	fields []Field
+	// cache is the last moduleLogger resolved, rebuilt when the global logger changes
+	cache atomic.Value
+}
+
+type moduleLogger struct {
+	global *zap.Logger
+	zl     *zap.Logger
+}
+
+// logger returns the zap logger to write to.
+func (l *Logger) logger() *zap.Logger {
+	if l.zl != nil {
+		return l.zl
+	}
+	global := customLogger()
+	if c, ok := l.cache.Load().(moduleLogger); ok && c.global == global {
+		return c.zl
+	}
+	name := l.module
+	zl := global.Named(name).WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return &levelCore{Core: unwrapLevel(c), enabled: moduleEnabler(name)}
+	})).With(l.fields...)
+	l.cache.Store(moduleLogger{global: global, zl: zl})
+	return zl
+}
+
+// With returns a child logger that adds fields to every entry.
+func With(fields ...Field) *Logger {
+	return &Logger{zl: customLogger().With(fields...)}
+}
+
+// Withw is like With but takes alternating keys and values.
+func Withw(keysAndValues ...interface{}) *Logger {
+	return With(sweeten(keysAndValues)...)
+}
+
+// With returns a child of l with additional fields.
+func (l *Logger) With(fields ...Field) *Logger {
+	if l.zl == nil {
+		all := append(l.fields[:len(l.fields):len(l.fields)], fields...)
+		return &Logger{module: l.module, fields: all}
+	}
+	return &Logger{zl: l.zl.With(fields...)}
+}
+
+// Zap returns the underlying zap logger. For a Module logger it is the one
+// bound to the current global logger.
+func (l *Logger) Zap() *zap.Logger {
+	return l.logger()
+}
+
+func (l *Logger) Debug(msg string, fields ...Field) {
+	l.logger().Debug(msg, fields...)
+}
+
+func (l *Logger) Debugf(template string, args ...interface{}) {
+	l.logger().Debug(fmt.Sprintf(template, args...))
+}
+
+func (l *Logger) Debugw(msg string, keysAndValues ...interface{}) {
+	l.logger().Debug(msg, sweeten(keysAndValues)...)
+}
+
+func (l *Logger) Error(msg string, fields ...Field) {
+	l.logger().Error(msg, fields...)
+}
+
+func (l *Logger) Errorf(template string, args ...interface{}) {
+	l.logger().Error(fmt.Sprintf(template, args...))
+}
+
+func (l *Logger) Errorw(msg string, keysAndValues ...interface{}) {
+	l.logger().Error(msg, sweeten(keysAndValues)...)
+}
+
+func (l *Logger) Info(msg string, fields ...Field) {
+	l.logger().Info(msg, fields...)
+}
+
+func (l *Logger) Infof(template string, args ...interface{}) {
+	l.logger().Info(fmt.Sprintf(template, args...))
+}
+
+func (l *Logger) Infow(msg string, keysAndValues ...interface{}) {
+	l.logger().Info(msg, sweeten(keysAndValues)...)
+}
+
+func (l *Logger) Warn(msg string, fields ...Field) {
+	l.logger().Warn(msg, fields...)
+}
+
+func (l *Logger) Warnf(template string, args ...interface{}) {
+	l.logger().Warn(fmt.Sprintf(template, args...))
+}
+
+func (l *Logger) Warnw(msg string, keysAndValues ...interface{}) {
+	l.logger().Warn(msg, sweeten(keysAndValues)...)
+}
+
+func (l *Logger) Fatal(msg string, fields ...Field) {
+	l.logger().Fatal(msg, fields...)
+}
+
+func (l *Logger) Fatalf(template string, args ...interface{}) {
+	l.logger().Fatal(fmt.Sprintf(template, args...))
+}
+
+func (l *Logger) Fatalw(msg string, keysAndValues ...interface{}) {
+	l.logger().Fatal(msg, sweeten(keysAndValues)...)
+}
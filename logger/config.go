@@ -0,0 +1,327 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
+)
+
+// Config describes how the global logger is built.
+type Config struct {
+	// Filename is the log file path. Empty disables file output unless Rotation.Pattern is set.
+	Filename string `json:"filename" yaml:"filename" toml:"filename"`
+	// Level is the minimum enabled level: debug, info, warn, error.
+	Level string `json:"level" yaml:"level" toml:"level"`
+	// Encoder is either "console" or "json".
+	Encoder string `json:"encoder" yaml:"encoder" toml:"encoder"`
+	// Stdout also writes every entry to standard output.
+	Stdout bool `json:"stdout" yaml:"stdout" toml:"stdout"`
+	// Caller adds the file:line of the call site.
+	Caller bool `json:"caller" yaml:"caller" toml:"caller"`
+	// ModuleLevels overrides the level of loggers returned by Module, e.g. {"gopool": "debug"}.
+	ModuleLevels map[string]string `json:"module_levels" yaml:"module_levels" toml:"module_levels"`
+	// Rotation controls how the log file is rotated.
+	Rotation RotationConfig `json:"rotation" yaml:"rotation" toml:"rotation"`
+	// Async buffers entries in memory and writes them from a background goroutine.
+	// Remote sinks are not affected: they always send from their own queue.
+	Async AsyncConfig `json:"async" yaml:"async" toml:"async"`
+	// Sampling limits repeated messages, e.g. an error storm from a failing dependency.
+	Sampling SamplingConfig `json:"sampling" yaml:"sampling" toml:"sampling"`
+	// Redact removes passwords, tokens and similar data from messages and fields.
+	Redact RedactConfig `json:"redact" yaml:"redact" toml:"redact"`
+	// Outputs lists several sinks, each with its own encoder and level.
+	// When set, Filename, Encoder and Stdout are ignored.
+	Outputs []OutputConfig `json:"outputs" yaml:"outputs" toml:"outputs"`
+}
+
+// OutputConfig describes one log sink.
+type OutputConfig struct {
+	// Path is a file path, "stdout" / "stderr", or a remote sink URL such as
+	// "syslog+udp://host:514", "http://collector/ingest", "tcp://host:5170" or "udp://host:5170".
+	Path string `json:"path" yaml:"path" toml:"path"`
+	// Level is the minimum level written to this sink. Empty writes everything
+	// the global level lets through.
+	Level string `json:"level" yaml:"level" toml:"level"`
+	// Encoder is either "console" or "json". The http, tcp and udp sinks send
+	// JSON lines, so they default to and only accept "json".
+	Encoder string `json:"encoder" yaml:"encoder" toml:"encoder"`
+	// Color colors the level names; only used by the console encoder.
+	Color bool `json:"color" yaml:"color" toml:"color"`
+	// Rotation overrides Config.Rotation for this file.
+	Rotation *RotationConfig `json:"rotation" yaml:"rotation" toml:"rotation"`
+	// Remote tunes batching, retries and spooling for remote sinks.
+	Remote RemoteConfig `json:"remote" yaml:"remote" toml:"remote"`
+}
+
+// RotationConfig holds the log file rotation settings.
+type RotationConfig struct {
+	// MaxSize is the maximum size in megabytes before the file is rotated.
+	MaxSize int `json:"max_size" yaml:"max_size" toml:"max_size"`
+	// MaxAge is the maximum number of days to keep rotated files.
+	MaxAge int `json:"max_age" yaml:"max_age" toml:"max_age"`
+	// MaxBackups is the maximum number of rotated files to keep. Zero keeps all.
+	MaxBackups int `json:"max_backups" yaml:"max_backups" toml:"max_backups"`
+	// LocalTime uses local time instead of UTC in rotated file names.
+	LocalTime bool `json:"local_time" yaml:"local_time" toml:"local_time"`
+	// Compress gzips rotated files.
+	Compress bool `json:"compress" yaml:"compress" toml:"compress"`
+	// Interval enables time-based rotation: "daily", "hourly" or a duration such as "30m".
+	// Empty rotates by size only.
+	Interval string `json:"interval" yaml:"interval" toml:"interval"`
+	// Pattern is the file name used with Interval, with %Y %m %d %H %M %S placeholders,
+	// e.g. "./log/app-%Y-%m-%d.log". It defaults to Filename with the date inserted.
+	Pattern string `json:"pattern" yaml:"pattern" toml:"pattern"`
+}
+
+// Environment variables read by ApplyEnv.
+const (
+	EnvFile       = "LOG_FILE"
+	EnvLevel      = "LOG_LEVEL"
+	EnvEncoder    = "LOG_ENCODER"
+	EnvStdout     = "LOG_STDOUT"
+	EnvCaller     = "LOG_CALLER"
+	EnvMaxSize    = "LOG_MAX_SIZE"
+	EnvMaxAge     = "LOG_MAX_AGE"
+	EnvMaxBackups = "LOG_MAX_BACKUPS"
+	EnvLocalTime  = "LOG_LOCAL_TIME"
+	EnvCompress   = "LOG_COMPRESS"
+	EnvInterval   = "LOG_ROTATE_INTERVAL"
+	EnvPattern    = "LOG_FILE_PATTERN"
+)
+
+// DefaultConfig returns the configuration used when the package is imported.
+func DefaultConfig() Config {
+	return Config{
+		Filename: "./log/test.log",
+		Level:    "info",
+		Encoder:  "console",
+		Caller:   true,
+		Rotation: RotationConfig{
+			MaxSize:   10,
+			MaxAge:    7,
+			LocalTime: true,
+			Compress:  true,
+		},
+	}
+}
+
+// ConfigFromEnv returns DefaultConfig overridden by the LOG_* environment variables.
+func ConfigFromEnv() (Config, error) {
+	cfg := DefaultConfig()
+	err := cfg.ApplyEnv()
+	return cfg, err
+}
+
+// LoadConfigFile reads a YAML, TOML or JSON file, chosen by extension.
+// Keys missing from the file keep their DefaultConfig values.
+func LoadConfigFile(path string) (Config, error) {
+	cfg := DefaultConfig()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, &cfg)
+	case ".json":
+		err = json.Unmarshal(data, &cfg)
+	default:
+		return cfg, fmt.Errorf("logger: unsupported config file type %q", path)
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("logger: parse %s: %w", path, err)
+	}
+	return cfg, cfg.Validate()
+}
+
+// ApplyEnv overrides c with any LOG_* environment variables that are set.
+func (c *Config) ApplyEnv() error {
+	if v, ok := os.LookupEnv(EnvFile); ok {
+		c.Filename = v
+	}
+	if v, ok := os.LookupEnv(EnvLevel); ok {
+		c.Level = v
+	}
+	if v, ok := os.LookupEnv(EnvEncoder); ok {
+		c.Encoder = v
+	}
+	if v, ok := os.LookupEnv(EnvModuleLevels); ok {
+		levels, err := parseModuleLevels(v)
+		if err != nil {
+			return err
+		}
+		c.ModuleLevels = levels
+	}
+	if v, ok := os.LookupEnv(EnvInterval); ok {
+		c.Rotation.Interval = v
+	}
+	if v, ok := os.LookupEnv(EnvPattern); ok {
+		c.Rotation.Pattern = v
+	}
+
+	bools := map[string]*bool{
+		EnvStdout:    &c.Stdout,
+		EnvCaller:    &c.Caller,
+		EnvLocalTime: &c.Rotation.LocalTime,
+		EnvCompress:  &c.Rotation.Compress,
+	}
+	for key, dst := range bools {
+		if v, ok := os.LookupEnv(key); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("logger: invalid %s=%q: %w", key, v, err)
+			}
+			*dst = b
+		}
+	}
+
+	ints := map[string]*int{
+		EnvMaxSize:    &c.Rotation.MaxSize,
+		EnvMaxAge:     &c.Rotation.MaxAge,
+		EnvMaxBackups: &c.Rotation.MaxBackups,
+	}
+	for key, dst := range ints {
+		if v, ok := os.LookupEnv(key); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("logger: invalid %s=%q: %w", key, v, err)
+			}
+			*dst = n
+		}
+	}
+	return c.Validate()
+}
+
+// Validate reports whether the level and encoder names are known.
+func (c Config) Validate() error {
+	if _, err := c.zapLevel(); err != nil {
+		return err
+	}
+	for module, level := range c.ModuleLevels {
+		if _, err := parseLevel(level); err != nil {
+			return fmt.Errorf("logger: module %s: %w", module, err)
+		}
+	}
+	if err := c.Async.validate(); err != nil {
+		return err
+	}
+	if err := c.Sampling.validate(); err != nil {
+		return err
+	}
+	if _, err := c.Redact.compile(); err != nil {
+		return err
+	}
+
+	if len(c.Outputs) == 0 && c.Rotation.Interval != "" && c.Filename == "" && c.Rotation.Pattern == "" {
+		return fmt.Errorf("logger: rotation interval %q needs a filename or pattern", c.Rotation.Interval)
+	}
+	outputs := c.outputs()
+	if len(outputs) == 0 {
+		return fmt.Errorf("logger: no output configured")
+	}
+	for _, o := range outputs {
+		if err := o.validate(c.Rotation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// outputs returns Outputs, or the single file/stdout sinks described by the top-level fields.
+func (c Config) outputs() []OutputConfig {
+	if len(c.Outputs) > 0 {
+		return c.Outputs
+	}
+	var outputs []OutputConfig
+	if c.Filename != "" || c.Rotation.Pattern != "" {
+		outputs = append(outputs, OutputConfig{Path: c.Filename, Encoder: c.Encoder})
+	}
+	if c.Stdout {
+		outputs = append(outputs, OutputConfig{Path: "stdout", Encoder: c.Encoder})
+	}
+	return outputs
+}
+
+// validate checks o; rotation is the top-level setting used when o.Rotation is nil.
+func (o OutputConfig) validate(rotation RotationConfig) error {
+	if o.Rotation != nil {
+		rotation = *o.Rotation
+	}
+	if o.Path == "" && (rotation.Interval == "" || rotation.Pattern == "") {
+		return fmt.Errorf("logger: output without path")
+	}
+	if o.Level != "" {
+		if _, err := parseLevel(o.Level); err != nil {
+			return fmt.Errorf("logger: output %s: %w", o.Path, err)
+		}
+	}
+	if isSinkURL(o.Path) {
+		u, err := url.Parse(o.Path)
+		if err != nil {
+			return fmt.Errorf("logger: invalid output %q: %w", o.Path, err)
+		}
+		if _, ok := sinkFactory(u.Scheme); !ok {
+			return fmt.Errorf("logger: no sink registered for %q", u.Scheme)
+		}
+		if jsonLineSchemes[u.Scheme] && o.Encoder == "console" {
+			return fmt.Errorf("logger: output %s: the %s sink sends JSON lines, encoder must be json", o.Path, u.Scheme)
+		}
+		if err := o.Remote.validate(); err != nil {
+			return err
+		}
+	}
+	switch o.Encoder {
+	case "", "console", "json":
+	default:
+		return fmt.Errorf("logger: output %s: unknown encoder %q", o.Path, o.Encoder)
+	}
+	if rotation.Interval != "" {
+		if _, err := parseInterval(rotation.Interval); err != nil {
+			return fmt.Errorf("logger: output %s: %w", o.Path, err)
+		}
+	} else if rotation.Pattern != "" && isFileOutput(o.Path) {
+		// without an interval the file is rotated by size and the pattern is never used
+		return fmt.Errorf("logger: output %s: rotation pattern %q needs an interval", o.Path, rotation.Pattern)
+	}
+	return nil
+}
+
+// jsonLineSchemes are the built-in sinks sending JSON lines.
+var jsonLineSchemes = map[string]bool{"http": true, "https": true, "tcp": true, "udp": true}
+
+// encoder returns o.Encoder, "json" by default for the JSON line sinks.
+func (o OutputConfig) encoder() string {
+	if o.Encoder == "" && isSinkURL(o.Path) {
+		if u, err := url.Parse(o.Path); err == nil && jsonLineSchemes[u.Scheme] {
+			return "json"
+		}
+	}
+	return o.Encoder
+}
+
+// isFileOutput reports whether path names a log file rather than a stream or sink.
+func isFileOutput(path string) bool {
+	return path != "stdout" && path != "stderr" && !isSinkURL(path)
+}
+
+func (c Config) zapLevel() (zapcore.Level, error) {
+	var level zapcore.Level
+	if c.Level == "" {
+		return zapcore.InfoLevel, nil
+	}
+	if err := level.UnmarshalText([]byte(c.Level)); err != nil {
+		return level, fmt.Errorf("logger: unknown level %q", c.Level)
+	}
+	return level, nil
+}
@@ -0,0 +1,141 @@
+package logger_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/liuxiaodao666/go-util/logger"
+)
+
+func TestLoadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"log.yaml": "filename: app.log\nlevel: warn\nencoder: json\nrotation:\n  max_size: 50\n",
+		"log.toml": "filename = \"app.log\"\nlevel = \"warn\"\nencoder = \"json\"\n[rotation]\nmax_size = 50\n",
+		"log.json": `{"filename": "app.log", "level": "warn", "encoder": "json", "rotation": {"max_size": 50}}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := logger.LoadConfigFile(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if cfg.Filename != "app.log" || cfg.Level != "warn" || cfg.Encoder != "json" || cfg.Rotation.MaxSize != 50 {
+			t.Errorf("%s: got %+v", name, cfg)
+		}
+		if cfg.Rotation.MaxAge != logger.DefaultConfig().Rotation.MaxAge {
+			t.Errorf("%s: MaxAge = %d, want default", name, cfg.Rotation.MaxAge)
+		}
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv(logger.EnvLevel, "debug")
+	t.Setenv(logger.EnvStdout, "true")
+	t.Setenv(logger.EnvMaxBackups, "3")
+
+	cfg, err := logger.ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Level != "debug" || !cfg.Stdout || cfg.Rotation.MaxBackups != 3 {
+		t.Errorf("got %+v", cfg)
+	}
+
+	t.Setenv(logger.EnvEncoder, "xml")
+	if _, err := logger.ConfigFromEnv(); err == nil {
+		t.Error("expected an error for an unknown encoder")
+	}
+}
+
+func TestInit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "init.log")
+	cfg := logger.DefaultConfig()
+	cfg.Filename = path
+	cfg.Level = "warn"
+	cfg.Encoder = "json"
+	if err := logger.Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Init(logger.DefaultConfig())
+
+	logger.Info("dropped info")
+	logger.Warnf("kept %s", "warn")
+	_ = logger.Sync()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	if strings.Contains(out, "dropped info") || !strings.Contains(out, `"msg":"kept warn"`) {
+		t.Errorf("unexpected log output: %s", out)
+	}
+}
+
+func TestInitWhileLogging(t *testing.T) {
+	dir := t.TempDir()
+	defer logger.Init(logger.DefaultConfig())
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				logger.Info("concurrent info")
+				_ = logger.GetWriter()
+			}
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		cfg := logger.DefaultConfig()
+		cfg.Filename = filepath.Join(dir, "reinit.log")
+		if err := logger.Init(cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	<-done
+}
+
+func TestRotationValidation(t *testing.T) {
+	cases := map[string]func(*logger.Config){
+		"pattern without interval": func(c *logger.Config) {
+			c.Rotation.Pattern = "app-%Y-%m-%d.log"
+		},
+		"interval without file": func(c *logger.Config) {
+			c.Filename = ""
+			c.Stdout = true
+			c.Rotation.Interval = "daily"
+		},
+		"output pattern without interval": func(c *logger.Config) {
+			c.Outputs = []logger.OutputConfig{{
+				Path:     "app.log",
+				Rotation: &logger.RotationConfig{Pattern: "app-%Y.log"},
+			}}
+		},
+	}
+	for name, modify := range cases {
+		cfg := logger.DefaultConfig()
+		modify(&cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: Validate() = nil, want an error", name)
+		}
+	}
+
+	cfg := logger.DefaultConfig()
+	cfg.Rotation.Interval = "daily"
+	cfg.Rotation.Pattern = "app-%Y-%m-%d.log"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("interval with pattern: %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package logger
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+type ctxKey string
+
+// Context keys registered by default. Store values with context.WithValue,
+// e.g. context.WithValue(ctx, logger.TraceIDKey, "4bf92f35").
+const (
+	RequestIDKey ctxKey = "request_id"
+	TraceIDKey   ctxKey = "trace_id"
+	SpanIDKey    ctxKey = "span_id"
+	UserIDKey    ctxKey = "user_id"
+)
+
+type loggerCtxKey struct{}
+
+type contextField struct {
+	key   interface{}
+	field string
+}
+
+var contextFields = struct {
+	sync.RWMutex
+	list []contextField
+}{list: []contextField{
+	{RequestIDKey, string(RequestIDKey)},
+	{TraceIDKey, string(TraceIDKey)},
+	{SpanIDKey, string(SpanIDKey)},
+	{UserIDKey, string(UserIDKey)},
+}}
+
+// RegisterContextKey makes FromContext log the value stored under key as field.
+// Registering the same key again changes its field name.
+func RegisterContextKey(key interface{}, field string) {
+	contextFields.Lock()
+	defer contextFields.Unlock()
+	for i, cf := range contextFields.list {
+		if cf.key == key {
+			contextFields.list[i].field = field
+			return
+		}
+	}
+	contextFields.list = append(contextFields.list, contextField{key: key, field: field})
+}
+
+// FromContext returns the logger stored by NewContext, or the global logger with
+// a field for every registered key present in ctx.
+func FromContext(ctx context.Context) *Logger {
+	if ctx == nil {
+		return &Logger{zl: customLogger()}
+	}
+	if l, ok := ctx.Value(loggerCtxKey{}).(*Logger); ok {
+		return l
+	}
+	fields := contextValues(ctx)
+	if len(fields) == 0 {
+		return &Logger{zl: customLogger()}
+	}
+	return &Logger{zl: customLogger().With(fields...)}
+}
+
+// NewContext stores FromContext(ctx) enriched with fields in the returned context.
+// The stored logger captures the registered values present in ctx at this point.
+func NewContext(ctx context.Context, fields ...Field) context.Context {
+	return context.WithValue(ctx, loggerCtxKey{}, FromContext(ctx).With(fields...))
+}
+
+func contextValues(ctx context.Context) []Field {
+	contextFields.RLock()
+	defer contextFields.RUnlock()
+	var fields []Field
+	for _, cf := range contextFields.list {
+		if v := ctx.Value(cf.key); v != nil {
+			fields = append(fields, zap.Any(cf.field, v))
+		}
+	}
+	return fields
+}
+
+func DebugCtx(ctx context.Context, msg string, fields ...Field) {
+	FromContext(ctx).logger().Debug(msg, fields...)
+}
+
+func InfoCtx(ctx context.Context, msg string, fields ...Field) {
+	FromContext(ctx).logger().Info(msg, fields...)
+}
+
+func WarnCtx(ctx context.Context, msg string, fields ...Field) {
+	FromContext(ctx).logger().Warn(msg, fields...)
+}
+
+func ErrorCtx(ctx context.Context, msg string, fields ...Field) {
+	FromContext(ctx).logger().Error(msg, fields...)
+}
@@ -0,0 +1,37 @@
+package logger_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/liuxiaodao666/go-util/logger"
+)
+
+type tenantKey struct{}
+
+func TestContextFields(t *testing.T) {
+	path := initJSONLogger(t)
+	logger.RegisterContextKey(tenantKey{}, "tenant")
+
+	ctx := context.WithValue(context.Background(), logger.TraceIDKey, "abc123")
+	ctx = context.WithValue(ctx, tenantKey{}, "acme")
+	logger.InfoCtx(ctx, "handled", logger.Int("status", 200))
+
+	ctx = logger.NewContext(ctx, logger.String("job", "resize"))
+	logger.FromContext(ctx).Warn("slow")
+	logger.ErrorCtx(context.Background(), "plain")
+
+	entries := readJSONLines(t, path)
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+	if entries[0]["trace_id"] != "abc123" || entries[0]["tenant"] != "acme" || entries[0]["status"] != float64(200) {
+		t.Errorf("entry 0 = %v", entries[0])
+	}
+	if entries[1]["trace_id"] != "abc123" || entries[1]["job"] != "resize" {
+		t.Errorf("entry 1 = %v", entries[1])
+	}
+	if _, ok := entries[2]["trace_id"]; ok {
+		t.Errorf("entry 2 = %v, want no trace_id", entries[2])
+	}
+}
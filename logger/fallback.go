@@ -0,0 +1,83 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// fallbackRetryInterval is how long the logger stays on stderr before trying the file again.
+var fallbackRetryInterval = 30 * time.Second
+
+// fallbackWriter writes to primary and switches to stderr when primary fails,
+// e.g. because the log directory is not writable. While degraded it retries
+// primary at most once per fallbackRetryInterval.
+type fallbackWriter struct {
+	mu       sync.Mutex
+	primary  zapcore.WriteSyncer
+	fallback zapcore.WriteSyncer
+	degraded bool
+	retryAt  time.Time
+	now      func() time.Time
+}
+
+func newFallbackWriter(primary zapcore.WriteSyncer) *fallbackWriter {
+	return &fallbackWriter{
+		primary:  primary,
+		fallback: zapcore.Lock(os.Stderr),
+		now:      time.Now,
+	}
+}
+
+func (w *fallbackWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.degraded && w.now().Before(w.retryAt) {
+		return w.fallback.Write(p)
+	}
+
+	n, err := w.primary.Write(p)
+	if err != nil {
+		if !w.degraded {
+			fmt.Fprintf(w.fallback, "logger: write log file failed, falling back to stderr: %v\n", err)
+		}
+		w.degraded = true
+		w.retryAt = w.now().Add(fallbackRetryInterval)
+		return w.fallback.Write(p)
+	}
+	if w.degraded {
+		w.degraded = false
+		fmt.Fprintln(w.fallback, "logger: log file is writable again, leaving stderr fallback")
+	}
+	return n, nil
+}
+
+func (w *fallbackWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.degraded {
+		return w.fallback.Sync()
+	}
+	return w.primary.Sync()
+}
+
+// Degraded reports whether entries currently go to stderr instead of the log file.
+func (w *fallbackWriter) Degraded() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.degraded
+}
+
+// newFallbackLogger returns a stderr logger used when the configured logger cannot be built.
+func newFallbackLogger() *zap.Logger {
+	core := &levelCore{
+		Core:    withHooks(zapcore.NewCore(getEncoder("console", false), zapcore.Lock(os.Stderr), zapcore.DebugLevel), nil),
+		enabled: atomicLevel.Enabled,
+	}
+	return zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1))
+}
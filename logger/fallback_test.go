@@ -0,0 +1,73 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+type flakyWriter struct {
+	fail bool
+	buf  bytes.Buffer
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	if w.fail {
+		return 0, errors.New("read-only file system")
+	}
+	return w.buf.Write(p)
+}
+
+func (w *flakyWriter) Sync() error { return nil }
+
+func TestFallbackWriter(t *testing.T) {
+	now := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	primary := &flakyWriter{fail: true}
+	var stderr bytes.Buffer
+	w := &fallbackWriter{
+		primary:  primary,
+		fallback: zapcore.AddSync(&stderr),
+		now:      func() time.Time { return now },
+	}
+
+	w.Write([]byte("one\n"))
+	if !w.Degraded() || !strings.Contains(stderr.String(), "falling back to stderr") || !strings.Contains(stderr.String(), "one\n") {
+		t.Fatalf("stderr = %q, want warning and entry", stderr.String())
+	}
+
+	primary.fail = false
+	w.Write([]byte("two\n"))
+	if primary.buf.Len() != 0 {
+		t.Fatal("primary retried before the retry interval")
+	}
+
+	now = now.Add(fallbackRetryInterval)
+	w.Write([]byte("three\n"))
+	if w.Degraded() || primary.buf.String() != "three\n" {
+		t.Fatalf("primary = %q, want recovery", primary.buf.String())
+	}
+}
+
+func TestInitUnwritableDirectory(t *testing.T) {
+	blocker := filepath.Join(t.TempDir(), "not-a-dir")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig()
+	cfg.Filename = filepath.Join(blocker, "app.log")
+	if err := Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer Init(DefaultConfig())
+
+	Info("goes to stderr")
+	if w, ok := GetWriter().(*fallbackWriter); !ok || !w.Degraded() {
+		t.Fatalf("writer = %T, want a degraded fallbackWriter", GetWriter())
+	}
+}
@@ -0,0 +1,52 @@
+package logger
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Field is a typed key-value pair attached to a log entry.
+type Field = zap.Field
+
+func String(key string, val string) Field          { return zap.String(key, val) }
+func Strings(key string, val []string) Field       { return zap.Strings(key, val) }
+func Int(key string, val int) Field                { return zap.Int(key, val) }
+func Int64(key string, val int64) Field            { return zap.Int64(key, val) }
+func Uint64(key string, val uint64) Field          { return zap.Uint64(key, val) }
+func Float64(key string, val float64) Field        { return zap.Float64(key, val) }
+func Bool(key string, val bool) Field              { return zap.Bool(key, val) }
+func Duration(key string, val time.Duration) Field { return zap.Duration(key, val) }
+func Time(key string, val time.Time) Field         { return zap.Time(key, val) }
+func Any(key string, val interface{}) Field        { return zap.Any(key, val) }
+func Stringer(key string, val fmt.Stringer) Field  { return zap.Stringer(key, val) }
+func NamedErr(key string, err error) Field         { return zap.NamedError(key, err) }
+
+// Err returns an "error" field. It is not called Error because Error logs a message.
+func Err(err error) Field { return zap.Error(err) }
+
+// sweeten turns alternating keys and values into fields, like zap's SugaredLogger.
+// A non-string key or a missing value is logged under "!BADKEY".
+func sweeten(keysAndValues []interface{}) []Field {
+	if len(keysAndValues) == 0 {
+		return nil
+	}
+	fields := make([]Field, 0, (len(keysAndValues)+1)/2)
+	for i := 0; i < len(keysAndValues); {
+		if f, ok := keysAndValues[i].(Field); ok {
+			fields = append(fields, f)
+			i++
+			continue
+		}
+		key, ok := keysAndValues[i].(string)
+		if !ok || i == len(keysAndValues)-1 {
+			fields = append(fields, zap.Any("!BADKEY", keysAndValues[i]))
+			i++
+			continue
+		}
+		fields = append(fields, zap.Any(key, keysAndValues[i+1]))
+		i += 2
+	}
+	return fields
+}
@@ -0,0 +1,89 @@
+package logger_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/liuxiaodao666/go-util/logger"
+)
+
+func TestStructuredFields(t *testing.T) {
+	path := initJSONLogger(t)
+
+	logger.Info("user login", logger.String("user", "alice"), logger.Int("attempt", 2))
+	logger.With(logger.String("module", "gopool")).Errorw("job failed", "job_id", 7, "err", errors.New("boom"))
+	logger.Warnw("odd", "dangling")
+
+	entries := readJSONLines(t, path)
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+	if entries[0]["user"] != "alice" || entries[0]["attempt"] != float64(2) {
+		t.Errorf("entry 0 = %v", entries[0])
+	}
+	if entries[1]["module"] != "gopool" || entries[1]["job_id"] != float64(7) || entries[1]["err"] != "boom" {
+		t.Errorf("entry 1 = %v", entries[1])
+	}
+	if entries[2]["!BADKEY"] != "dangling" {
+		t.Errorf("entry 2 = %v", entries[2])
+	}
+	if caller, _ := entries[0]["caller"].(string); !strings.HasPrefix(caller, "logger/field_test.go") {
+		t.Errorf("caller = %q, want the test file", caller)
+	}
+}
+
+// initJSONLogger points the global logger at a JSON file in a temp dir, at debug level.
+func initJSONLogger(t *testing.T) string {
+	t.Helper()
+	return initJSONLoggerWith(t, nil)
+}
+
+// initJSONLoggerWith is initJSONLogger with a hook to adjust the config.
+func initJSONLoggerWith(t *testing.T, adjust func(cfg *logger.Config)) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.log")
+	cfg := logger.DefaultConfig()
+	cfg.Filename = path
+	cfg.Encoder = "json"
+	cfg.Level = "debug"
+	if adjust != nil {
+		adjust(&cfg)
+	}
+	if err := logger.Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { logger.Init(logger.DefaultConfig()) })
+	return path
+}
+
+func readJSONLines(t *testing.T, path string) []map[string]interface{} {
+	t.Helper()
+	_ = logger.Sync()
+	return readJSONLinesNoSync(t, path)
+}
+
+// readJSONLinesNoSync is readJSONLines without flushing the logger first.
+func readJSONLinesNoSync(t *testing.T, path string) []map[string]interface{} {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var entries []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
@@ -0,0 +1,401 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// HookEntry is the entry passed to a Hook.
+type HookEntry struct {
+	Time    time.Time              `json:"time"`
+	Level   string                 `json:"level"`
+	Logger  string                 `json:"logger,omitempty"`
+	Caller  string                 `json:"caller,omitempty"`
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+	// Stack is the stack of the logging goroutine, with HookOptions.Stack.
+	Stack string `json:"stack,omitempty"`
+	// Goroutines is a dump of every goroutine, with HookOptions.Goroutines.
+	// It is taken when the hook runs, shortly after the entry was logged.
+	Goroutines string `json:"goroutines,omitempty"`
+}
+
+// Hook handles an entry at or above its level. A returned error is counted and
+// printed to stderr; hooks must not log at their own level, or they feed themselves.
+type Hook func(HookEntry) error
+
+// HookOptions controls when and how a Hook runs.
+type HookOptions struct {
+	// Level is the minimum level: "error" (default), "dpanic", "panic" or "fatal".
+	// Lower levels work too.
+	Level string
+	// Limit is the number of calls allowed per Interval; the rest are dropped.
+	// Default 10.
+	Limit int
+	// Interval is the rate limit window. Default 1 minute.
+	Interval time.Duration
+	// QueueSize is the number of entries waiting for the hook. Default 64.
+	QueueSize int
+	// Stack attaches the stack of the goroutine that logged the entry.
+	Stack bool
+	// Goroutines attaches a dump of all goroutines.
+	Goroutines bool
+}
+
+// HookStats counts what happened to the entries sent to a hook.
+type HookStats struct {
+	Fired   uint64 `json:"fired"`
+	Dropped uint64 `json:"dropped"`
+	Failed  uint64 `json:"failed"`
+}
+
+type hook struct {
+	name     string
+	fn       Hook
+	level    zapcore.Level
+	opts     HookOptions
+	queue    chan HookEntry
+	done     chan struct{}
+	mu       sync.Mutex
+	window   time.Time
+	inWindow int
+
+	pending                int64
+	fired, dropped, failed uint64
+}
+
+// fatalHookTimeout bounds how long a Fatal call waits for the hooks before exiting.
+var fatalHookTimeout = 5 * time.Second
+
+var hooks struct {
+	sync.RWMutex
+	list []*hook
+	// min is the lowest hook level, so entries below it skip the hooks cheaply
+	min int32
+}
+
+func init() {
+	hooks.min = int32(zapcore.FatalLevel) + 1
+}
+
+// RegisterHook runs fn in a background goroutine for every entry at or above
+// opts.Level, from any logger, rate limited so an error storm cannot pile up work.
+// Logging never waits for a hook: entries beyond the limit or the queue are dropped
+// and counted. Registering a name again replaces the previous hook.
+// The returned function unregisters the hook and waits for its queue to drain.
+func RegisterHook(name string, fn Hook, opts HookOptions) (unregister func(), err error) {
+	level := zapcore.ErrorLevel
+	if opts.Level != "" {
+		if level, err = parseLevel(opts.Level); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = 10
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = time.Minute
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 64
+	}
+	h := &hook{
+		name:  name,
+		fn:    fn,
+		level: level,
+		opts:  opts,
+		queue: make(chan HookEntry, opts.QueueSize),
+		done:  make(chan struct{}),
+	}
+	go h.run()
+
+	hooks.Lock()
+	var old *hook
+	for i, e := range hooks.list {
+		if e.name == name {
+			old = e
+			hooks.list = append(hooks.list[:i:i], hooks.list[i+1:]...)
+			break
+		}
+	}
+	hooks.list = append(hooks.list, h)
+	updateHookMin()
+	hooks.Unlock()
+	if old != nil {
+		old.stop()
+	}
+
+	return func() {
+		hooks.Lock()
+		for i, e := range hooks.list {
+			if e == h {
+				hooks.list = append(hooks.list[:i:i], hooks.list[i+1:]...)
+				break
+			}
+		}
+		updateHookMin()
+		hooks.Unlock()
+		h.stop()
+	}, nil
+}
+
+// updateHookMin recomputes hooks.min. hooks must be locked.
+func updateHookMin() {
+	min := int32(zapcore.FatalLevel) + 1
+	for _, h := range hooks.list {
+		if int32(h.level) < min {
+			min = int32(h.level)
+		}
+	}
+	atomic.StoreInt32(&hooks.min, min)
+}
+
+// Hooks returns the statistics of the registered hooks by name.
+func Hooks() map[string]HookStats {
+	hooks.RLock()
+	defer hooks.RUnlock()
+	out := make(map[string]HookStats, len(hooks.list))
+	for _, h := range hooks.list {
+		out[h.name] = HookStats{
+			Fired:   atomic.LoadUint64(&h.fired),
+			Dropped: atomic.LoadUint64(&h.dropped),
+			Failed:  atomic.LoadUint64(&h.failed),
+		}
+	}
+	return out
+}
+
+// FlushHooks waits until the queued entries of every hook have been handled.
+func FlushHooks(ctx context.Context) error {
+	hooks.RLock()
+	list := append([]*hook(nil), hooks.list...)
+	hooks.RUnlock()
+
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+	for _, h := range list {
+		for atomic.LoadInt64(&h.pending) > 0 {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return nil
+}
+
+// allow reports whether the rate limit lets one more entry through at now.
+func (h *hook) allow(now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if now.Sub(h.window) >= h.opts.Interval {
+		h.window, h.inWindow = now, 0
+	}
+	if h.inWindow >= h.opts.Limit {
+		return false
+	}
+	h.inWindow++
+	return true
+}
+
+func (h *hook) enqueue(e HookEntry) {
+	atomic.AddInt64(&h.pending, 1)
+	select {
+	case h.queue <- e:
+	default:
+		atomic.AddInt64(&h.pending, -1)
+		atomic.AddUint64(&h.dropped, 1)
+	}
+}
+
+func (h *hook) run() {
+	defer close(h.done)
+	for e := range h.queue {
+		h.call(e)
+		atomic.AddInt64(&h.pending, -1)
+	}
+}
+
+func (h *hook) call(e HookEntry) {
+	defer func() {
+		if r := recover(); r != nil {
+			atomic.AddUint64(&h.failed, 1)
+			fmt.Fprintf(os.Stderr, "logger: hook %s panicked: %v\n", h.name, r)
+		}
+	}()
+	if h.opts.Goroutines {
+		e.Goroutines = goroutineDump()
+	}
+	atomic.AddUint64(&h.fired, 1)
+	if err := h.fn(e); err != nil {
+		atomic.AddUint64(&h.failed, 1)
+		fmt.Fprintf(os.Stderr, "logger: hook %s failed: %v\n", h.name, err)
+	}
+}
+
+func (h *hook) stop() {
+	close(h.queue)
+	<-h.done
+}
+
+func goroutineDump() string {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= 8<<20 {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// hookCore hands entries to the registered hooks before writing them to the wrapped core.
+type hookCore struct {
+	zapcore.Core
+	fields []zapcore.Field
+	// rules redact the entries the hooks see like the outputs do; nil for none
+	rules *redactRules
+}
+
+func withHooks(core zapcore.Core, rules *redactRules) zapcore.Core {
+	return &hookCore{Core: core, rules: rules}
+}
+
+func (c *hookCore) With(fields []zapcore.Field) zapcore.Core {
+	return &hookCore{
+		Core:   c.Core.With(fields),
+		fields: append(c.fields[:len(c.fields):len(c.fields)], fields...),
+		rules:  c.rules,
+	}
+}
+
+func (c *hookCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if int32(ent.Level) >= atomic.LoadInt32(&hooks.min) {
+		ce = ce.AddCore(ent, &hookWriter{fields: c.fields, rules: c.rules})
+	}
+	return c.Core.Check(ent, ce)
+}
+
+// hookWriter is added to a checked entry so Write sees the call's fields.
+type hookWriter struct {
+	fields []zapcore.Field
+	rules  *redactRules
+}
+
+func (w *hookWriter) Enabled(zapcore.Level) bool        { return true }
+func (w *hookWriter) With([]zapcore.Field) zapcore.Core { return w }
+func (w *hookWriter) Sync() error                       { return nil }
+func (w *hookWriter) Check(_ zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce
+}
+
+func (w *hookWriter) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	hooks.RLock()
+	var e *HookEntry
+	var stack string
+	for _, h := range hooks.list {
+		if ent.Level < h.level {
+			continue
+		}
+		if !h.allow(ent.Time) {
+			atomic.AddUint64(&h.dropped, 1)
+			continue
+		}
+		if e == nil {
+			e = newHookEntry(ent, w.fields, fields, w.rules)
+		}
+		he := *e
+		if h.opts.Stack {
+			if stack == "" {
+				stack = string(debug.Stack())
+			}
+			he.Stack = stack
+		}
+		h.enqueue(he)
+	}
+	hooks.RUnlock()
+
+	// Fatal exits right after the write: give the hooks a chance to report it
+	if ent.Level == zapcore.FatalLevel && e != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), fatalHookTimeout)
+		defer cancel()
+		_ = FlushHooks(ctx)
+	}
+	return nil
+}
+
+// newHookEntry builds the entry handed to the hooks, redacted with rules if not nil.
+func newHookEntry(ent zapcore.Entry, with, fields []zapcore.Field, rules *redactRules) *HookEntry {
+	if rules != nil {
+		ent.Message = rules.redact("", ent.Message)
+	}
+	e := &HookEntry{
+		Time:    ent.Time,
+		Level:   ent.Level.CapitalString(),
+		Logger:  ent.LoggerName,
+		Message: ent.Message,
+	}
+	if ent.Caller.Defined {
+		e.Caller = ent.Caller.TrimmedPath()
+	}
+	if len(with)+len(fields) > 0 {
+		m := zapcore.NewMapObjectEncoder()
+		var enc zapcore.ObjectEncoder = m
+		if rules != nil {
+			enc = &redactObjectEncoder{ObjectEncoder: m, rules: rules}
+		}
+		for _, f := range with {
+			f.AddTo(enc)
+		}
+		for _, f := range fields {
+			f.AddTo(enc)
+		}
+		e.Fields = m.Fields
+	}
+	return e
+}
+
+// WebhookHook returns a Hook that POSTs each entry as JSON to url.
+// A non-2xx response is an error.
+func WebhookHook(url string, timeout time.Duration) Hook {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	client := &http.Client{Timeout: timeout}
+	return func(e HookEntry) error {
+		body, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("webhook %s: %s", url, resp.Status)
+		}
+		return nil
+	}
+}
+
+// CounterHook returns a Hook that increments the counter for the entry's level,
+// e.g. a Prometheus counter vector's WithLabelValues(level).Inc.
+func CounterHook(inc func(level string)) Hook {
+	return func(e HookEntry) error {
+		inc(e.Level)
+		return nil
+	}
+}
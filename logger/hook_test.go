@@ -0,0 +1,206 @@
+package logger_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/liuxiaodao666/go-util/logger"
+	"github.com/liuxiaodao666/go-util/logger/logtest"
+)
+
+func flushHooks(t *testing.T) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := logger.FlushHooks(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHookFiresOnError(t *testing.T) {
+	logtest.Observe(t)
+	var mu sync.Mutex
+	var got []logger.HookEntry
+	unregister, err := logger.RegisterHook("collect", func(e logger.HookEntry) error {
+		mu.Lock()
+		got = append(got, e)
+		mu.Unlock()
+		return nil
+	}, logger.HookOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unregister()
+
+	logger.Info("not an error")
+	logger.Warn("not an error either")
+	logger.With(logger.String("order", "42")).Error("payment failed", logger.Err(errors.New("card declined")))
+	flushHooks(t)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 1 {
+		t.Fatalf("hook got %d entries, want 1", len(got))
+	}
+	e := got[0]
+	if e.Level != "ERROR" || e.Message != "payment failed" || e.Fields["order"] != "42" ||
+		e.Fields["error"] != "card declined" || !strings.HasPrefix(e.Caller, "logger/hook_test.go:") {
+		t.Errorf("entry = %+v", e)
+	}
+	if s := logger.Hooks()["collect"]; s.Fired != 1 || s.Dropped != 0 {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+func TestHookRedaction(t *testing.T) {
+	initJSONLoggerWith(t, func(cfg *logger.Config) {
+		cfg.Redact = logger.RedactConfig{Fields: []string{"password"}, Patterns: []string{"email"}}
+	})
+	var mu sync.Mutex
+	var got []logger.HookEntry
+	unregister, err := logger.RegisterHook("redacted", func(e logger.HookEntry) error {
+		mu.Lock()
+		got = append(got, e)
+		mu.Unlock()
+		return nil
+	}, logger.HookOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unregister()
+
+	logger.With(logger.String("password", "hunter2")).Error("login failed for alice@example.com",
+		logger.Any("meta", map[string]string{"password": "nested", "contact": "bob@example.org"}))
+	flushHooks(t)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 1 {
+		t.Fatalf("hook got %d entries, want 1", len(got))
+	}
+	b, _ := json.Marshal(got[0])
+	for _, secret := range []string{"hunter2", "nested", "@example.com", "@example.org"} {
+		if strings.Contains(string(b), secret) {
+			t.Errorf("hook entry contains %q: %s", secret, b)
+		}
+	}
+	if got[0].Fields["password"] != "[REDACTED]" {
+		t.Errorf("password = %v, want [REDACTED]", got[0].Fields["password"])
+	}
+}
+
+func TestHookRateLimit(t *testing.T) {
+	logtest.Observe(t)
+	var mu sync.Mutex
+	counts := map[string]int{}
+	unregister, err := logger.RegisterHook("metrics", logger.CounterHook(func(level string) {
+		mu.Lock()
+		counts[level]++
+		mu.Unlock()
+	}), logger.HookOptions{Level: "warn", Limit: 3, Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unregister()
+
+	for i := 0; i < 10; i++ {
+		logger.Errorf("storm %d", i)
+	}
+	flushHooks(t)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if counts["ERROR"] != 3 {
+		t.Errorf("counted %v, want 3 errors", counts)
+	}
+	if s := logger.Hooks()["metrics"]; s.Fired != 3 || s.Dropped != 7 {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+func TestHookDoesNotBlock(t *testing.T) {
+	logtest.Observe(t)
+	release := make(chan struct{})
+	unregister, err := logger.RegisterHook("slow", func(logger.HookEntry) error {
+		<-release
+		return nil
+	}, logger.HookOptions{Limit: 1000, QueueSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		logger.Error("storm")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("logging took %v with a stuck hook", d)
+	}
+	close(release)
+	unregister()
+
+	if _, ok := logger.Hooks()["slow"]; ok {
+		t.Error("hook still registered")
+	}
+}
+
+func TestWebhookHook(t *testing.T) {
+	logtest.Observe(t)
+	received := make(chan logger.HookEntry, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e logger.HookEntry
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Error(err)
+		}
+		received <- e
+	}))
+	defer srv.Close()
+
+	unregister, err := logger.RegisterHook("webhook", logger.WebhookHook(srv.URL, time.Second),
+		logger.HookOptions{Stack: true, Goroutines: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unregister()
+
+	logger.Error("disk full")
+	select {
+	case e := <-received:
+		if e.Message != "disk full" || !strings.Contains(e.Stack, "TestWebhookHook") ||
+			!strings.Contains(e.Goroutines, "goroutine ") {
+			t.Errorf("webhook got %+v", e)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook not called")
+	}
+}
+
+func TestHookFailure(t *testing.T) {
+	logtest.Observe(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	unregister, err := logger.RegisterHook("failing", logger.WebhookHook(srv.URL, time.Second), logger.HookOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unregister()
+	if _, err := logger.RegisterHook("bad", nil, logger.HookOptions{Level: "loud"}); err == nil {
+		t.Error("RegisterHook accepted an unknown level")
+	}
+
+	logger.Error("boom")
+	flushHooks(t)
+	if s := logger.Hooks()["failing"]; s.Failed != 1 {
+		t.Errorf("stats = %+v, want 1 failure", s)
+	}
+}
@@ -0,0 +1,278 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// EnvModuleLevels holds per-module level overrides, e.g. "gopool=debug,http=warn".
+const EnvModuleLevels = "LOG_MODULE_LEVELS"
+
+// atomicLevel is the global level. It survives Init so handlers keep working.
+var atomicLevel = zap.NewAtomicLevel()
+
+var moduleLevels = struct {
+	sync.RWMutex
+	m map[string]zapcore.Level
+}{m: map[string]zapcore.Level{}}
+
+// SetLevel changes the global level at runtime.
+func SetLevel(level string) error {
+	l, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	atomicLevel.SetLevel(l)
+	return nil
+}
+
+// GetLevel returns the global level.
+func GetLevel() string {
+	return atomicLevel.Level().String()
+}
+
+// SetModuleLevel overrides the level of the logger returned by Module(module).
+// An empty level removes the override.
+func SetModuleLevel(module, level string) error {
+	if level == "" {
+		moduleLevels.Lock()
+		delete(moduleLevels.m, module)
+		moduleLevels.Unlock()
+		return nil
+	}
+	l, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	moduleLevels.Lock()
+	moduleLevels.m[module] = l
+	moduleLevels.Unlock()
+	return nil
+}
+
+// ModuleLevels returns a copy of the per-module overrides.
+func ModuleLevels() map[string]string {
+	moduleLevels.RLock()
+	defer moduleLevels.RUnlock()
+	out := make(map[string]string, len(moduleLevels.m))
+	for k, v := range moduleLevels.m {
+		out[k] = v.String()
+	}
+	return out
+}
+
+// setModuleLevels replaces all overrides.
+func setModuleLevels(levels map[string]string) error {
+	parsed := make(map[string]zapcore.Level, len(levels))
+	for module, level := range levels {
+		l, err := parseLevel(level)
+		if err != nil {
+			return err
+		}
+		parsed[module] = l
+	}
+	moduleLevels.Lock()
+	moduleLevels.m = parsed
+	moduleLevels.Unlock()
+	return nil
+}
+
+func moduleEnabler(module string) zap.LevelEnablerFunc {
+	return func(l zapcore.Level) bool {
+		moduleLevels.RLock()
+		lvl, ok := moduleLevels.m[module]
+		moduleLevels.RUnlock()
+		if ok {
+			return l >= lvl
+		}
+		return atomicLevel.Enabled(l)
+	}
+}
+
+// Module returns a named child logger whose level can be overridden with SetModuleLevel,
+// so one noisy package can be turned up to debug alone. It follows Init and ReplaceCore,
+// so it can be created at import time:
+//
+//	var log = logger.Module("gopool")
+func Module(name string) *Logger {
+	return &Logger{module: name}
+}
+
+// levelCore filters entries with its own enabler instead of the wrapped core's.
+// The wrapped core is built at DebugLevel, so the filter decides alone.
+type levelCore struct {
+	zapcore.Core
+	enabled zap.LevelEnablerFunc
+}
+
+func (c *levelCore) Enabled(l zapcore.Level) bool {
+	return c.enabled(l)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), enabled: c.enabled}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.enabled(ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+func unwrapLevel(c zapcore.Core) zapcore.Core {
+	if lc, ok := c.(*levelCore); ok {
+		return lc.Core
+	}
+	return c
+}
+
+func parseLevel(level string) (zapcore.Level, error) {
+	var l zapcore.Level
+	if err := l.UnmarshalText([]byte(strings.TrimSpace(level))); err != nil {
+		return l, fmt.Errorf("logger: unknown level %q", level)
+	}
+	return l, nil
+}
+
+// parseModuleLevels parses "gopool=debug,http=warn".
+func parseModuleLevels(s string) (map[string]string, error) {
+	out := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("logger: invalid module level %q", pair)
+		}
+		out[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return out, nil
+}
+
+type levelPayload struct {
+	Level   string            `json:"level"`
+	Module  string            `json:"module,omitempty"`
+	Modules map[string]string `json:"modules,omitempty"`
+}
+
+// LevelHandler reports the levels on GET and changes them on PUT.
+// PUT takes {"level":"debug"} for the global level or {"module":"gopool","level":"debug"}
+// for one module; an empty module level removes the override.
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var req levelPayload
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeLevelError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+				return
+			}
+			var err error
+			if req.Module != "" {
+				err = SetModuleLevel(req.Module, req.Level)
+			} else {
+				err = SetLevel(req.Level)
+			}
+			if err != nil {
+				writeLevelError(w, http.StatusBadRequest, err)
+				return
+			}
+			Infof("log level changed via http: module=%q level=%q", req.Module, req.Level)
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			writeLevelError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(levelPayload{Level: GetLevel(), Modules: ModuleLevels()})
+	})
+}
+
+func writeLevelError(w http.ResponseWriter, code int, err error) {
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// ReloadLevelsFromEnv re-reads LOG_LEVEL and LOG_MODULE_LEVELS.
+// Unset variables leave the current levels unchanged.
+func ReloadLevelsFromEnv() error {
+	if v, ok := os.LookupEnv(EnvLevel); ok {
+		if err := SetLevel(v); err != nil {
+			return err
+		}
+	}
+	if v, ok := os.LookupEnv(EnvModuleLevels); ok {
+		levels, err := parseModuleLevels(v)
+		if err != nil {
+			return err
+		}
+		return setModuleLevels(levels)
+	}
+	return nil
+}
+
+// ReloadLevelsFromFile re-reads the level and module levels from the config file at
+// path, with the LOG_* environment variables still taking precedence as at startup.
+// Module levels missing from both are cleared; the other settings are not reloaded.
+func ReloadLevelsFromFile(path string) error {
+	cfg, err := LoadConfigFile(path)
+	if err != nil {
+		return err
+	}
+	if err := cfg.ApplyEnv(); err != nil {
+		return err
+	}
+	level, _ := cfg.zapLevel()
+	if err := setModuleLevels(cfg.ModuleLevels); err != nil {
+		return err
+	}
+	atomicLevel.SetLevel(level)
+	return nil
+}
+
+// WatchLevelSignal reloads the levels from the config file at path, see
+// ReloadLevelsFromFile, on every SIGHUP until ctx is done. Edit the file, then
+// send the signal, e.g. kill -HUP <pid>.
+func WatchLevelSignal(ctx context.Context, path string) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(ch)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ch:
+				if err := ReloadLevelsFromFile(path); err != nil {
+					Errorf("reload log level from %s failed: %v", path, err)
+					continue
+				}
+				Infof("log level reloaded from %s: level=%s modules=%s", path, GetLevel(), formatModuleLevels(ModuleLevels()))
+			}
+		}
+	}()
+}
+
+func formatModuleLevels(levels map[string]string) string {
+	pairs := make([]string, 0, len(levels))
+	for k, v := range levels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,133 @@
+package logger_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/liuxiaodao666/go-util/logger"
+)
+
+func TestModuleLevel(t *testing.T) {
+	path := initJSONLogger(t)
+	if err := logger.SetLevel("warn"); err != nil {
+		t.Fatal(err)
+	}
+	pool := logger.Module("gopool")
+
+	logger.Debug("global debug")
+	pool.Debug("pool debug before")
+	if err := logger.SetModuleLevel("gopool", "debug"); err != nil {
+		t.Fatal(err)
+	}
+	pool.With(logger.Int("worker", 1)).Debug("pool debug after")
+	logger.Info("global info")
+
+	entries := readJSONLines(t, path)
+	if len(entries) != 1 || entries[0]["msg"] != "pool debug after" || entries[0]["logger"] != "gopool" {
+		t.Fatalf("entries = %v, want only the module debug entry", entries)
+	}
+}
+
+// earlyLog is created at import time, before any test calls Init.
+var earlyLog = logger.Module("early")
+
+func TestModuleBeforeInit(t *testing.T) {
+	child := earlyLog.With(logger.Int("n", 1))
+	for i := 0; i < 2; i++ {
+		// each Init closes the outputs of the previous one
+		path := initJSONLogger(t)
+		earlyLog.Info("plain")
+		child.Info("with field")
+
+		entries := readJSONLines(t, path)
+		if len(entries) != 2 || entries[0]["logger"] != "early" || entries[1]["msg"] != "with field" ||
+			entries[1]["n"] != 1.0 {
+			t.Fatalf("Init %d: entries = %v, want both module entries in the new output", i, entries)
+		}
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	initJSONLogger(t)
+	h := logger.LevelHandler()
+
+	do := func(method, body string) (int, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, "/log/level", strings.NewReader(body)))
+		out := map[string]interface{}{}
+		_ = json.Unmarshal(rec.Body.Bytes(), &out)
+		return rec.Code, out
+	}
+
+	if code, out := do(http.MethodGet, ""); code != http.StatusOK || out["level"] != "debug" {
+		t.Fatalf("GET = %d %v", code, out)
+	}
+	if code, out := do(http.MethodPut, `{"level":"error"}`); code != http.StatusOK || out["level"] != "error" {
+		t.Fatalf("PUT level = %d %v", code, out)
+	}
+	code, out := do(http.MethodPut, `{"module":"http","level":"debug"}`)
+	if modules, _ := out["modules"].(map[string]interface{}); code != http.StatusOK || modules["http"] != "debug" {
+		t.Fatalf("PUT module = %d %v", code, out)
+	}
+	if code, _ := do(http.MethodPut, `{"level":"loud"}`); code != http.StatusBadRequest {
+		t.Fatalf("PUT bad level = %d, want 400", code)
+	}
+	if code, _ := do(http.MethodPost, ""); code != http.StatusMethodNotAllowed {
+		t.Fatalf("POST = %d, want 405", code)
+	}
+}
+
+func TestReloadLevelsFromEnv(t *testing.T) {
+	initJSONLogger(t)
+	t.Setenv(logger.EnvLevel, "error")
+	t.Setenv(logger.EnvModuleLevels, "gopool=debug, http=warn")
+
+	if err := logger.ReloadLevelsFromEnv(); err != nil {
+		t.Fatal(err)
+	}
+	if logger.GetLevel() != "error" {
+		t.Errorf("level = %s, want error", logger.GetLevel())
+	}
+	if m := logger.ModuleLevels(); m["gopool"] != "debug" || m["http"] != "warn" {
+		t.Errorf("module levels = %v", m)
+	}
+}
+
+func TestWatchLevelSignal(t *testing.T) {
+	initJSONLogger(t)
+	path := filepath.Join(t.TempDir(), "log.yaml")
+	if err := os.WriteFile(path, []byte("level: info\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger.WatchLevelSignal(ctx, path)
+
+	// the file changes while the process runs, then the signal picks it up
+	content := "level: error\nmodule_levels:\n  gopool: debug\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := os.FindProcess(os.Getpid())
+	if err := p.Signal(syscall.SIGHUP); err != nil {
+		t.Skipf("cannot send SIGHUP: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for logger.GetLevel() != "error" {
+		if time.Now().After(deadline) {
+			t.Fatalf("level = %s after SIGHUP, want error", logger.GetLevel())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if m := logger.ModuleLevels(); m["gopool"] != "debug" {
+		t.Errorf("module levels = %v", m)
+	}
+}
@@ -0,0 +1,332 @@
+// Package logfile lists, reads, searches and prunes the files written by package logger,
+// including the archives left behind by rotation. It does not import logger, so tools
+// built on it do not initialize a global logger.
+package logfile
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const compressSuffix = ".gz"
+
+// File is a log file: the active file or a rotated archive.
+type File struct {
+	Path       string
+	Size       int64
+	ModTime    time.Time
+	Compressed bool
+	// Active is the file the logger currently writes to.
+	Active bool
+}
+
+// List returns the files belonging to name, oldest first. name is the configured
+// Filename, e.g. "./log/test.log", or a rotation Pattern such as "./log/app-%Y-%m-%d.log".
+// The active file is name itself when it exists, otherwise the newest uncompressed
+// match, which is the current file of a time-rotated log.
+func List(name string) ([]File, error) {
+	matches, err := filepath.Glob(fileGlob(name))
+	if err != nil {
+		return nil, err
+	}
+	re := NameRegexp(name)
+
+	var files []File
+	for _, m := range matches {
+		if !re.MatchString(filepath.Base(m)) {
+			continue
+		}
+		info, err := os.Stat(m)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		files = append(files, File{
+			Path:       m,
+			Size:       info.Size(),
+			ModTime:    info.ModTime(),
+			Compressed: strings.HasSuffix(m, compressSuffix),
+		})
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].ModTime.Equal(files[j].ModTime) {
+			return files[i].Path < files[j].Path
+		}
+		return files[i].ModTime.Before(files[j].ModTime)
+	})
+
+	active := -1
+	for i := len(files) - 1; i >= 0; i-- {
+		if filepath.Clean(files[i].Path) == filepath.Clean(name) {
+			active = i
+			break
+		}
+		if active < 0 && !files[i].Compressed {
+			active = i
+		}
+	}
+	if active >= 0 {
+		files[active].Active = true
+	}
+	return files, nil
+}
+
+// fileGlob is a coarse match for the files of name, narrowed down by NameRegexp.
+func fileGlob(name string) string {
+	name = strings.NewReplacer(
+		"%Y", "*", "%m", "*", "%d", "*", "%H", "*", "%M", "*", "%S", "*", "%%", "%",
+	).Replace(name)
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + "*" + ext + "*"
+}
+
+// NameRegexp matches the base names of the files written for name, each optionally
+// followed by ".gz":
+//   - for a rotation Pattern, the placeholders filled in, with an optional ".N" size
+//     suffix before the extension;
+//   - for a Filename, the file itself, lumberjack backups (name-2006-01-02T15-04-05.000.log)
+//     and the files of the pattern derived from it when rotating by time
+//     (name-2006-01-02.log, name-2006-01-02-15.log, name-2006-01-02-1504.log, with ".N").
+//
+// Other files sharing the prefix, e.g. app-error.log next to app.log, do not match.
+func NameRegexp(name string) *regexp.Regexp {
+	base := filepath.Base(name)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	var expr string
+	if strings.Contains(name, "%") {
+		expr = placeholderRegexp(stem) + `(\.[0-9]+)?`
+	} else {
+		expr = regexp.QuoteMeta(stem) +
+			`(-[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}-[0-9]{2}-[0-9]{2}\.[0-9]{3}` +
+			`|-[0-9]{4}-[0-9]{2}-[0-9]{2}(-[0-9]{2}([0-9]{2})?)?(\.[0-9]+)?)?`
+	}
+	return regexp.MustCompile("^" + expr + placeholderRegexp(ext) + `(\.gz)?$`)
+}
+
+// placeholderRegexp quotes pattern, turning %Y into four digits and %m %d %H %M %S into two.
+func placeholderRegexp(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '%' && i+1 < len(pattern) {
+			i++
+			switch pattern[i] {
+			case 'Y':
+				b.WriteString("[0-9]{4}")
+				continue
+			case 'm', 'd', 'H', 'M', 'S':
+				b.WriteString("[0-9]{2}")
+				continue
+			case '%':
+				b.WriteString("%")
+				continue
+			}
+			b.WriteString("%")
+		}
+		b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+	}
+	return b.String()
+}
+
+// Open opens a log file for reading, decompressing .gz archives transparently.
+func Open(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, compressSuffix) {
+		return f, nil
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("logfile: %s: %w", path, err)
+	}
+	return &gzipFile{Reader: gz, f: f}, nil
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	f *os.File
+}
+
+func (g *gzipFile) Close() error {
+	err := g.Reader.Close()
+	if ferr := g.f.Close(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// Decompress writes the decompressed content of the archive path to dst and returns
+// the written file name. The archive is kept. When dst is empty the file goes to a
+// "decompressed" directory next to the archive, named like it without ".gz": written
+// next to the archive, it would be listed, searched and pruned with the log set.
+func Decompress(path, dst string) (string, error) {
+	if !strings.HasSuffix(path, compressSuffix) {
+		return "", fmt.Errorf("logfile: %s is not compressed", path)
+	}
+	if dst == "" {
+		dir := filepath.Join(filepath.Dir(path), "decompressed")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", err
+		}
+		dst = filepath.Join(dir, strings.TrimSuffix(filepath.Base(path), compressSuffix))
+	}
+	src, err := Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", err
+	}
+	if _, err = io.Copy(out, src); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return "", err
+	}
+	if err = out.Close(); err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(path); err == nil {
+		_ = os.Chtimes(dst, info.ModTime(), info.ModTime())
+	}
+	return dst, nil
+}
+
+// Match is a line found by Search.
+type Match struct {
+	Path string
+	Line int
+	Text string
+}
+
+// Search scans every file belonging to name, oldest first, and calls fn for each line
+// matching re. It stops early when fn returns false.
+func Search(name string, re *regexp.Regexp, fn func(Match) bool) error {
+	files, err := List(name)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		more, err := searchFile(f.Path, re, fn)
+		if err != nil {
+			return err
+		}
+		if !more {
+			return nil
+		}
+	}
+	return nil
+}
+
+func searchFile(path string, re *regexp.Regexp, fn func(Match) bool) (bool, error) {
+	r, err := Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	defer r.Close()
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		if re.Match(sc.Bytes()) && !fn(Match{Path: path, Line: n, Text: sc.Text()}) {
+			return false, nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return false, fmt.Errorf("logfile: %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// Policy decides which files Prune removes. Zero values disable a limit.
+type Policy struct {
+	// MaxTotalSize is the disk quota in bytes for all files, the active one included.
+	MaxTotalSize int64
+	// MaxFiles is the number of archives kept, not counting the active file.
+	MaxFiles int
+	// MaxAge removes archives last modified longer ago.
+	MaxAge time.Duration
+	// DryRun reports what would be removed without touching any file.
+	DryRun bool
+}
+
+// Prune applies p to the files belonging to name and returns the removed files.
+// Archives go oldest first. The active file is never removed; it is truncated only
+// when it alone still exceeds MaxTotalSize after every archive is gone, and is then
+// returned with Active set.
+func Prune(name string, p Policy, now time.Time) ([]File, error) {
+	files, err := List(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var archives []File
+	var active *File
+	var total int64
+	for i := range files {
+		total += files[i].Size
+		if files[i].Active {
+			active = &files[i]
+			continue
+		}
+		archives = append(archives, files[i])
+	}
+
+	var removed []File
+	var errs []string
+	remove := func(f File) {
+		if !p.DryRun {
+			if err := os.Remove(f.Path); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err.Error())
+				return
+			}
+		}
+		removed = append(removed, f)
+		total -= f.Size
+	}
+
+	// archives is oldest first
+	for len(archives) > 0 {
+		f := archives[0]
+		over := (p.MaxFiles > 0 && len(archives) > p.MaxFiles) ||
+			(p.MaxAge > 0 && now.Sub(f.ModTime) > p.MaxAge) ||
+			(p.MaxTotalSize > 0 && total > p.MaxTotalSize)
+		if !over {
+			break
+		}
+		archives = archives[1:]
+		remove(f)
+	}
+
+	if active != nil && p.MaxTotalSize > 0 && total > p.MaxTotalSize && len(errs) == 0 {
+		if !p.DryRun {
+			// loggers open the file with O_APPEND, so they keep writing from the new end
+			if err := os.Truncate(active.Path, 0); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		if len(errs) == 0 {
+			removed = append(removed, *active)
+		}
+	}
+
+	if len(errs) > 0 {
+		return removed, fmt.Errorf("logfile: prune: %s", strings.Join(errs, "; "))
+	}
+	return removed, nil
+}
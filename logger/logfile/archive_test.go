@@ -0,0 +1,247 @@
+package logfile
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var base = time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+// writeLog creates a file with content, modified day days after base.
+func writeLog(t *testing.T, path, content string, day int, compress bool) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if compress {
+		gz := gzip.NewWriter(f)
+		gz.Write([]byte(content))
+		gz.Close()
+	} else {
+		f.WriteString(content)
+	}
+	f.Close()
+	mt := base.AddDate(0, 0, day)
+	if err := os.Chtimes(path, mt, mt); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func setup(t *testing.T) (dir, name string) {
+	dir = t.TempDir()
+	name = filepath.Join(dir, "app.log")
+	writeLog(t, filepath.Join(dir, "app-2024-03-07T00-00-00.000.log.gz"), "old error one\nold info\n", -3, true)
+	writeLog(t, filepath.Join(dir, "app-2024-03-08T00-00-00.000.log.gz"), "info two\n", -2, true)
+	writeLog(t, filepath.Join(dir, "app-2024-03-09T00-00-00.000.log"), "error three\n", -1, false)
+	writeLog(t, name, "current error\n", 0, false)
+	writeLog(t, filepath.Join(dir, "other.log"), "error elsewhere\n", 0, false)
+	return dir, name
+}
+
+func paths(files []File) []string {
+	var out []string
+	for _, f := range files {
+		out = append(out, filepath.Base(f.Path))
+	}
+	return out
+}
+
+func TestList(t *testing.T) {
+	_, name := setup(t)
+	files, err := List(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "app-2024-03-07T00-00-00.000.log.gz app-2024-03-08T00-00-00.000.log.gz app-2024-03-09T00-00-00.000.log app.log"
+	if got := strings.Join(paths(files), " "); got != want {
+		t.Fatalf("List = %s\nwant %s", got, want)
+	}
+	if !files[3].Active || files[2].Active || !files[0].Compressed || files[2].Compressed {
+		t.Errorf("flags = %+v", files)
+	}
+}
+
+func TestListPattern(t *testing.T) {
+	dir := t.TempDir()
+	writeLog(t, filepath.Join(dir, "app-2024-03-09.log.gz"), "a\n", -1, true)
+	writeLog(t, filepath.Join(dir, "app-2024-03-10.log"), "b\n", 0, false)
+	writeLog(t, filepath.Join(dir, "app-2024-03-10.1.log"), "c\n", 0, false)
+
+	files, err := List(filepath.Join(dir, "app-%Y-%m-%d.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("List = %v", paths(files))
+	}
+	if active := files[2]; !active.Active || filepath.Base(active.Path) != "app-2024-03-10.log" {
+		t.Errorf("active = %+v", active)
+	}
+}
+
+func TestNameRegexp(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		match map[string]bool
+	}{
+		{"./log/app-%Y-%m-%d.log", map[string]bool{
+			"app-2026-10-18.log":      true,
+			"app-2026-10-18.2.log":    true,
+			"app-2026-10-18.log.gz":   true,
+			"app-2026-10-18.2.log.gz": true,
+			"app-worker-2026.log":     false,
+			"app-2026-10-18.log.bak":  false,
+			"app-2026-10-18x.log":     false,
+		}},
+		{"./log/app.log", map[string]bool{
+			"app.log":                             true,
+			"app.log.gz":                          true,
+			"app-2024-03-07T00-00-00.000.log":     true,
+			"app-2024-03-07T00-00-00.000.log.gz":  true,
+			"app-2024-03-07.log":                  true,
+			"app-2024-03-07-13.log":               true,
+			"app-2024-03-07-1330.1.log.gz":        true,
+			"app-error.log":                       false,
+			"app.err.log":                         false,
+			"app-2024-03-07T00-00-00.000.log.bak": false,
+			"app-2024-03-07T00-00-00.000-old.log": false,
+			"appx.log":                            false,
+		}},
+	} {
+		re := NameRegexp(tc.name)
+		for name, want := range tc.match {
+			if got := re.MatchString(name); got != want {
+				t.Errorf("%s: match %q = %v, want %v", tc.name, name, got, want)
+			}
+		}
+	}
+}
+
+func TestListSkipsSiblings(t *testing.T) {
+	dir, name := setup(t)
+	writeLog(t, filepath.Join(dir, "app-error.log"), "error sibling\n", 0, false)
+	writeLog(t, filepath.Join(dir, "app.err.log.gz"), "error sibling\n", 0, true)
+
+	files, err := List(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "app-2024-03-07T00-00-00.000.log.gz app-2024-03-08T00-00-00.000.log.gz app-2024-03-09T00-00-00.000.log app.log"
+	if got := strings.Join(paths(files), " "); got != want {
+		t.Fatalf("List = %s\nwant %s", got, want)
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	dir, _ := setup(t)
+	src := filepath.Join(dir, "app-2024-03-07T00-00-00.000.log.gz")
+	dst, err := Decompress(src, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(dst)
+	if string(b) != "old error one\nold info\n" {
+		t.Errorf("content = %q", b)
+	}
+	if _, err := os.Stat(src); err != nil {
+		t.Errorf("archive removed: %v", err)
+	}
+	// the copy stays out of the log set
+	files, err := List(filepath.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if f.Path == dst {
+			t.Errorf("decompressed file %s listed with the archives", dst)
+		}
+	}
+	if _, err := Decompress(src, ""); !os.IsExist(err) {
+		t.Errorf("second Decompress err = %v, want exists", err)
+	}
+	if _, err := Decompress(dst, ""); err == nil {
+		t.Error("Decompress of a plain file succeeded")
+	}
+}
+
+func TestSearch(t *testing.T) {
+	_, name := setup(t)
+	var got []string
+	err := Search(name, regexp.MustCompile("error"), func(m Match) bool {
+		got = append(got, filepath.Base(m.Path)+":"+m.Text)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "app-2024-03-07T00-00-00.000.log.gz:old error one|app-2024-03-09T00-00-00.000.log:error three|app.log:current error"
+	if s := strings.Join(got, "|"); s != want {
+		t.Errorf("Search = %s\nwant %s", s, want)
+	}
+
+	n := 0
+	Search(name, regexp.MustCompile("."), func(Match) bool { n++; return n < 2 })
+	if n != 2 {
+		t.Errorf("Search did not stop, n = %d", n)
+	}
+}
+
+func TestPruneMaxFilesAndAge(t *testing.T) {
+	_, name := setup(t)
+	removed, err := Prune(name, Policy{MaxFiles: 2}, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := paths(removed); len(got) != 1 || got[0] != "app-2024-03-07T00-00-00.000.log.gz" {
+		t.Errorf("MaxFiles removed %v", got)
+	}
+
+	removed, err = Prune(name, Policy{MaxAge: 36 * time.Hour}, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := paths(removed); len(got) != 1 || got[0] != "app-2024-03-08T00-00-00.000.log.gz" {
+		t.Errorf("MaxAge removed %v", got)
+	}
+	files, _ := List(name)
+	if len(files) != 2 {
+		t.Errorf("left %v", paths(files))
+	}
+}
+
+func TestPruneQuota(t *testing.T) {
+	_, name := setup(t)
+	files, _ := List(name)
+	var total int64
+	for _, f := range files {
+		total += f.Size
+	}
+
+	removed, err := Prune(name, Policy{MaxTotalSize: total - 1, DryRun: true}, base)
+	if err != nil || len(removed) != 1 {
+		t.Fatalf("dry run removed %v, %v", paths(removed), err)
+	}
+	if left, _ := List(name); len(left) != 4 {
+		t.Fatalf("dry run deleted files: %v", paths(left))
+	}
+
+	// the active file alone is over the quota: every archive goes, then it is truncated
+	removed, err = Prune(name, Policy{MaxTotalSize: 5}, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 4 || !removed[3].Active {
+		t.Fatalf("removed %v", paths(removed))
+	}
+	left, _ := List(name)
+	if len(left) != 1 || left[0].Size != 0 {
+		t.Errorf("left %+v", left)
+	}
+}
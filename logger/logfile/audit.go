@@ -0,0 +1,234 @@
+package logfile
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Audit entries are JSON lines chained by hash:
+//
+//	{"seq":1,"ts":"...","event":"user.login","fields":{...},"prev":"<hash>","hash":"<hash>"}
+//
+// hash is AuditHash of the line up to, not including, `,"hash":` with "}" appended,
+// and prev is the hash of the entry before, or GenesisHash for the first one.
+// Editing, removing or reordering an entry breaks the chain at that point.
+
+// GenesisHash is the prev hash of the first audit entry.
+var GenesisHash = strings.Repeat("0", 64)
+
+const auditHashKey = `,"hash":"`
+
+// AuditHash returns the hex SHA-256 of body, or its HMAC-SHA256 when key is set.
+// With a key, an attacker who can rewrite the files cannot rebuild a valid chain.
+func AuditHash(key, body []byte) string {
+	if len(key) > 0 {
+		m := hmac.New(sha256.New, key)
+		m.Write(body)
+		return hex.EncodeToString(m.Sum(nil))
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditRecord is one parsed audit entry.
+type AuditRecord struct {
+	Seq    uint64                 `json:"seq"`
+	Time   time.Time              `json:"ts"`
+	Event  string                 `json:"event"`
+	Fields map[string]interface{} `json:"fields,omitempty"`
+	Prev   string                 `json:"prev"`
+	Hash   string                 `json:"hash"`
+	// body is the hashed part of the line.
+	body []byte
+}
+
+// ParseAuditRecord parses one audit line.
+func ParseAuditRecord(line []byte) (AuditRecord, error) {
+	line = bytes.TrimRight(line, "\r\n")
+	i := bytes.LastIndex(line, []byte(auditHashKey))
+	if i < 0 || !bytes.HasSuffix(line, []byte(`"}`)) {
+		return AuditRecord{}, errors.New("not an audit entry")
+	}
+	var r AuditRecord
+	if err := json.Unmarshal(line, &r); err != nil {
+		return AuditRecord{}, err
+	}
+	r.body = append(append([]byte(nil), line[:i]...), '}')
+	return r, nil
+}
+
+// AuditProblem is a break in the chain.
+type AuditProblem struct {
+	Path   string `json:"path"`
+	Line   int    `json:"line"`
+	Seq    uint64 `json:"seq"`
+	Reason string `json:"reason"`
+}
+
+func (p AuditProblem) String() string {
+	return fmt.Sprintf("%s:%d: seq %d: %s", p.Path, p.Line, p.Seq, p.Reason)
+}
+
+// AuditReport is the result of VerifyAudit.
+type AuditReport struct {
+	Files    int            `json:"files"`
+	Entries  int            `json:"entries"`
+	LastSeq  uint64         `json:"last_seq"`
+	LastHash string         `json:"last_hash"`
+	Problems []AuditProblem `json:"problems,omitempty"`
+}
+
+// OK reports whether the chain is intact.
+func (r *AuditReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// VerifyAudit checks the hash chain across every file belonging to name, oldest first.
+// It detects edited, deleted and reordered entries, including whole missing archives.
+// Entries cut from the end of the newest file leave a valid shorter chain; compare
+// LastSeq and LastHash with a copy kept elsewhere to catch that.
+func VerifyAudit(name string, key []byte) (*AuditReport, error) {
+	files, err := List(name)
+	if err != nil {
+		return nil, err
+	}
+	rep := &AuditReport{}
+	v := &auditVerifier{key: key, rep: rep, prevHash: GenesisHash}
+	for _, f := range files {
+		rep.Files++
+		r, err := Open(f.Path)
+		if err != nil {
+			return nil, err
+		}
+		err = v.verify(f.Path, r)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("logfile: %s: %w", f.Path, err)
+		}
+	}
+	rep.LastSeq, rep.LastHash = v.prevSeq, v.prevHash
+	return rep, nil
+}
+
+type auditVerifier struct {
+	key      []byte
+	rep      *AuditReport
+	prevSeq  uint64
+	prevHash string
+}
+
+func (v *auditVerifier) verify(path string, r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		problem := func(seq uint64, format string, args ...interface{}) {
+			v.rep.Problems = append(v.rep.Problems, AuditProblem{
+				Path: path, Line: n, Seq: seq, Reason: fmt.Sprintf(format, args...),
+			})
+		}
+
+		rec, err := ParseAuditRecord(sc.Bytes())
+		if err != nil {
+			problem(0, "unparsable entry: %v", err)
+			continue
+		}
+		v.rep.Entries++
+
+		if got := AuditHash(v.key, rec.body); !hmac.Equal([]byte(got), []byte(rec.Hash)) {
+			problem(rec.Seq, "hash mismatch: entry was modified")
+		}
+		switch {
+		case rec.Seq == v.prevSeq+1:
+			if rec.Prev != v.prevHash {
+				problem(rec.Seq, "prev hash mismatch: the entry before was modified or replaced")
+			}
+		case v.prevSeq == 0:
+			problem(rec.Seq, "chain starts at seq %d: earlier entries are missing", rec.Seq)
+		case rec.Seq > v.prevSeq+1:
+			problem(rec.Seq, "entries %d-%d are missing", v.prevSeq+1, rec.Seq-1)
+		default:
+			problem(rec.Seq, "out of order after seq %d", v.prevSeq)
+		}
+		v.prevSeq, v.prevHash = rec.Seq, rec.Hash
+	}
+	return sc.Err()
+}
+
+// TornAuditError reports a partial entry at the end of the newest audit file, left
+// by a crash in the middle of a write.
+type TornAuditError struct {
+	Path string
+	Line []byte
+}
+
+func (e *TornAuditError) Error() string {
+	return fmt.Sprintf("logfile: %s: torn audit entry at the end (%d bytes)", e.Path, len(e.Line))
+}
+
+// LastAuditRecord returns the newest entry among the files belonging to name.
+// ok is false when there is none yet. A partial line at the end of the newest file
+// is skipped: the entry before it is returned with a *TornAuditError.
+func LastAuditRecord(name string) (rec AuditRecord, ok bool, err error) {
+	files, err := List(name)
+	if err != nil {
+		return rec, false, err
+	}
+	var torn error
+	for i := len(files) - 1; i >= 0; i-- {
+		last, partial, err := lastLine(files[i].Path)
+		if err != nil {
+			return rec, false, err
+		}
+		if partial != nil && i == len(files)-1 {
+			torn = &TornAuditError{Path: files[i].Path, Line: partial}
+		}
+		if last == nil {
+			continue
+		}
+		rec, err = ParseAuditRecord(last)
+		if err != nil {
+			return rec, false, fmt.Errorf("logfile: %s: last audit entry: %w", files[i].Path, err)
+		}
+		return rec, true, torn
+	}
+	return rec, false, torn
+}
+
+// lastLine returns the last non-blank line of path ending in a newline, and what
+// follows it without one.
+func lastLine(path string) (last, partial []byte, err error) {
+	r, err := Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Close()
+
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			if len(bytes.TrimSpace(line)) > 0 {
+				partial = line
+			}
+			return last, partial, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			last = line
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package logfile
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Entry is one parsed log entry.
+type Entry struct {
+	Time    time.Time
+	Level   string
+	Logger  string
+	Caller  string
+	Message string
+	Fields  map[string]interface{}
+	// Stack holds the lines following the entry, usually a stacktrace.
+	Stack string
+	// Raw is the entry as written, continuation lines included.
+	Raw string
+}
+
+// levels orders the capital level names written by the logger.
+var levels = map[string]int{
+	"DEBUG": -1, "INFO": 0, "WARN": 1, "ERROR": 2, "DPANIC": 3, "PANIC": 4, "FATAL": 5,
+}
+
+// LevelEnabled reports whether level is at least min. An empty min enables every level.
+func LevelEnabled(level, min string) bool {
+	if min == "" {
+		return true
+	}
+	l, ok := levels[strings.ToUpper(level)]
+	m, mok := levels[strings.ToUpper(min)]
+	return ok && mok && l >= m
+}
+
+// ValidLevel reports whether level is a known level name, in any case.
+func ValidLevel(level string) bool {
+	_, ok := levels[strings.ToUpper(level)]
+	return ok
+}
+
+var callerRe = regexp.MustCompile(`^\S+\.go:\d+$`)
+
+// ParseEntry parses the first line of an entry written by the console encoder
+// (time, level, logger, caller, message and JSON fields separated by tabs) or by
+// the json encoder. ok is false when line does not start an entry.
+func ParseEntry(line string) (e Entry, ok bool) {
+	line = strings.TrimRight(line, "\r\n")
+	if strings.HasPrefix(line, "{") {
+		return parseJSONEntry(line)
+	}
+
+	parts := strings.Split(line, "\t")
+	if len(parts) < 3 {
+		return Entry{}, false
+	}
+	t, err := time.Parse(time.RFC3339, parts[0])
+	if err != nil {
+		return Entry{}, false
+	}
+	if _, known := levels[stripColor(parts[1])]; !known {
+		return Entry{}, false
+	}
+	e = Entry{Time: t, Level: stripColor(parts[1]), Raw: line}
+
+	rest := parts[2:]
+	if len(rest) > 1 && !callerRe.MatchString(rest[0]) && callerRe.MatchString(rest[1]) {
+		e.Logger, rest = rest[0], rest[1:]
+	}
+	if len(rest) > 1 && callerRe.MatchString(rest[0]) {
+		e.Caller, rest = rest[0], rest[1:]
+	}
+	if n := len(rest); n > 1 && strings.HasPrefix(rest[n-1], "{") {
+		var fields map[string]interface{}
+		if json.Unmarshal([]byte(rest[n-1]), &fields) == nil {
+			e.Fields, rest = fields, rest[:n-1]
+		}
+	}
+	e.Message = strings.Join(rest, "\t")
+	return e, true
+}
+
+func parseJSONEntry(line string) (Entry, bool) {
+	var m map[string]interface{}
+	if json.Unmarshal([]byte(line), &m) != nil {
+		return Entry{}, false
+	}
+	ts, _ := m["ts"].(string)
+	t, err := time.Parse(time.RFC3339, ts)
+	if err != nil {
+		return Entry{}, false
+	}
+	e := Entry{Time: t, Raw: line}
+	e.Level, _ = m["level"].(string)
+	e.Logger, _ = m["logger"].(string)
+	e.Caller, _ = m["caller"].(string)
+	e.Message, _ = m["msg"].(string)
+	e.Stack, _ = m["stacktrace"].(string)
+	for _, k := range []string{"ts", "level", "logger", "caller", "msg", "stacktrace"} {
+		delete(m, k)
+	}
+	if len(m) > 0 {
+		e.Fields = m
+	}
+	return e, true
+}
+
+// stripColor removes the ANSI color codes of the color level encoder.
+func stripColor(s string) string {
+	if i := strings.IndexByte(s, 'm'); strings.HasPrefix(s, "\x1b[") && i > 0 {
+		s = strings.TrimSuffix(s[i+1:], "\x1b[0m")
+	}
+	return s
+}
+
+// Filter selects entries. Zero values match everything.
+type Filter struct {
+	// Level is the minimum level, e.g. "warn".
+	Level string
+	// Since and Until bound the entry time, inclusive.
+	Since, Until time.Time
+	// Caller matches entries whose caller contains it.
+	Caller string
+	// Pattern matches against the raw entry.
+	Pattern *regexp.Regexp
+}
+
+// Match reports whether e passes every condition of f.
+func (f Filter) Match(e Entry) bool {
+	if !LevelEnabled(e.Level, f.Level) {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && e.Time.After(f.Until) {
+		return false
+	}
+	if f.Caller != "" && !strings.Contains(e.Caller, f.Caller) {
+		return false
+	}
+	if f.Pattern != nil && !f.Pattern.MatchString(e.Raw) {
+		return false
+	}
+	return true
+}
+
+// entryBuilder joins continuation lines, such as stacktraces, to the entry before them.
+// Lines before the first entry are returned as entries with only Raw and Message set.
+type entryBuilder struct {
+	cur Entry
+	has bool
+}
+
+// push adds a line and returns the previous entry once line starts a new one.
+func (b *entryBuilder) push(line string) (Entry, bool) {
+	line = strings.TrimRight(line, "\r\n")
+	e, ok := ParseEntry(line)
+	if !ok && b.has {
+		b.cur.Raw += "\n" + line
+		if b.cur.Stack != "" {
+			b.cur.Stack += "\n"
+		}
+		b.cur.Stack += line
+		return Entry{}, false
+	}
+	if !ok {
+		e = Entry{Message: line, Raw: line}
+	}
+	prev, had := b.cur, b.has
+	b.cur, b.has = e, true
+	return prev, had
+}
+
+// flush returns the pending entry.
+func (b *entryBuilder) flush() (Entry, bool) {
+	e, ok := b.cur, b.has
+	b.cur, b.has = Entry{}, false
+	return e, ok
+}
+
+// ReadEntries parses every entry in r and calls fn for the ones matching f.
+// It stops early when fn returns false.
+func ReadEntries(r io.Reader, f Filter, fn func(Entry) bool) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	var b entryBuilder
+	for sc.Scan() {
+		if e, ok := b.push(sc.Text()); ok && f.Match(e) && !fn(e) {
+			return nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if e, ok := b.flush(); ok && f.Match(e) {
+		fn(e)
+	}
+	return nil
+}
+
+// Query reads the files belonging to name, oldest first, and calls fn for every entry
+// matching f. Files last modified before f.Since are skipped. It stops early when fn
+// returns false.
+func Query(name string, f Filter, fn func(Entry) bool) error {
+	files, err := List(name)
+	if err != nil {
+		return err
+	}
+	stop := false
+	for _, file := range files {
+		if !f.Since.IsZero() && file.ModTime.Before(f.Since) {
+			continue
+		}
+		r, err := Open(file.Path)
+		if err != nil {
+			return err
+		}
+		err = ReadEntries(r, f, func(e Entry) bool {
+			stop = !fn(e)
+			return !stop
+		})
+		r.Close()
+		if err != nil || stop {
+			return err
+		}
+	}
+	return nil
+}
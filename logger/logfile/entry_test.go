@@ -0,0 +1,108 @@
+package logfile
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// encode writes entries the way the logger does and returns the output.
+func encode(t *testing.T, json bool, fn func(l *zap.Logger)) string {
+	t.Helper()
+	encCfg := zap.NewProductionEncoderConfig()
+	encCfg.EncodeTime = zapcore.TimeEncoderOfLayout(time.RFC3339)
+	encCfg.EncodeLevel = zapcore.CapitalLevelEncoder
+	enc := zapcore.NewConsoleEncoder(encCfg)
+	if json {
+		enc = zapcore.NewJSONEncoder(encCfg)
+	}
+	var buf bytes.Buffer
+	l := zap.New(zapcore.NewCore(enc, zapcore.AddSync(&buf), zapcore.DebugLevel), zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
+	fn(l)
+	return buf.String()
+}
+
+func sample(l *zap.Logger) {
+	l.Info("server started", zap.Int("port", 8080))
+	l.Named("db").Warn("slow query")
+	l.Error("request failed", zap.Error(errors.New("boom")))
+	l.Debug("tab\tin message")
+}
+
+func TestReadEntries(t *testing.T) {
+	for _, json := range []bool{false, true} {
+		out := encode(t, json, sample)
+		var got []Entry
+		if err := ReadEntries(strings.NewReader(out), Filter{}, func(e Entry) bool {
+			got = append(got, e)
+			return true
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 4 {
+			t.Fatalf("json=%v: %d entries, want 4:\n%s", json, len(got), out)
+		}
+
+		e := got[0]
+		if e.Level != "INFO" || e.Message != "server started" || e.Fields["port"] != float64(8080) ||
+			!strings.HasPrefix(e.Caller, "logfile/entry_test.go:") || e.Time.IsZero() {
+			t.Errorf("json=%v: entry 0 = %+v", json, e)
+		}
+		if e := got[1]; e.Logger != "db" || e.Level != "WARN" || e.Message != "slow query" {
+			t.Errorf("json=%v: entry 1 = %+v", json, e)
+		}
+		if e := got[2]; e.Fields["error"] != "boom" || !strings.Contains(e.Stack, "TestReadEntries") {
+			t.Errorf("json=%v: entry 2 = %+v", json, e)
+		}
+		if e := got[3]; e.Message != "tab\tin message" {
+			t.Errorf("json=%v: entry 3 message = %q", json, e.Message)
+		}
+	}
+}
+
+func TestParseEntryColor(t *testing.T) {
+	e, ok := ParseEntry("2024-03-10T12:00:00Z\t\x1b[31mERROR\x1b[0m\tmain.go:10\tfailed")
+	if !ok || e.Level != "ERROR" || e.Caller != "main.go:10" || e.Message != "failed" {
+		t.Errorf("ParseEntry = %+v, %v", e, ok)
+	}
+	if _, ok := ParseEntry("goroutine 1 [running]:"); ok {
+		t.Error("continuation line parsed as an entry")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	out := encode(t, false, sample)
+	count := func(f Filter) int {
+		n := 0
+		ReadEntries(strings.NewReader(out), f, func(Entry) bool { n++; return true })
+		return n
+	}
+
+	if n := count(Filter{Level: "warn"}); n != 2 {
+		t.Errorf("level warn = %d, want 2", n)
+	}
+	if n := count(Filter{Pattern: regexp.MustCompile(`port|boom`)}); n != 2 {
+		t.Errorf("pattern = %d, want 2", n)
+	}
+	if n := count(Filter{Caller: "entry_test.go"}); n != 4 {
+		t.Errorf("caller = %d, want 4", n)
+	}
+	if n := count(Filter{Caller: "other.go"}); n != 0 {
+		t.Errorf("caller other = %d, want 0", n)
+	}
+	if n := count(Filter{Since: time.Now().Add(time.Hour)}); n != 0 {
+		t.Errorf("since future = %d, want 0", n)
+	}
+	if n := count(Filter{Until: time.Now().Add(time.Hour), Since: time.Now().Add(-time.Hour)}); n != 4 {
+		t.Errorf("time range = %d, want 4", n)
+	}
+	if LevelEnabled("INFO", "bogus") || !ValidLevel("warn") || ValidLevel("verbose") {
+		t.Error("level helpers")
+	}
+}
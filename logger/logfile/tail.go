@@ -0,0 +1,210 @@
+package logfile
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+// TailOptions controls Tail.
+type TailOptions struct {
+	// Lines is the number of existing matching entries printed first. Zero prints none.
+	Lines int
+	// Follow keeps reading new entries until the context is done, switching to the
+	// new active file when the log rotates.
+	Follow bool
+	// Poll is how often the file is checked for new data. Default 250ms.
+	Poll time.Duration
+	// Hold is how long, when following, the last entry waits for continuation lines
+	// such as a stacktrace written in several parts. It is delivered as soon as the
+	// next entry starts, or once the file was quiet for Hold. Default 1s.
+	Hold   time.Duration
+	Filter Filter
+}
+
+// Tail calls fn with the last opts.Lines entries of the active file belonging to name
+// and, with opts.Follow, every entry written after that.
+func Tail(ctx context.Context, name string, opts TailOptions, fn func(Entry)) error {
+	if opts.Poll <= 0 {
+		opts.Poll = 250 * time.Millisecond
+	}
+	if opts.Hold <= 0 {
+		opts.Hold = time.Second
+	}
+	path, err := activePath(name)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	t := &tailer{f: f, filter: opts.Filter}
+	defer func() { t.f.Close() }()
+
+	// read to the end of the file, keeping only the last Lines entries
+	var last []Entry
+	t.fn = func(e Entry) {
+		if opts.Lines <= 0 {
+			return
+		}
+		if len(last) == opts.Lines {
+			last = last[1:]
+		}
+		last = append(last, e)
+	}
+	if err := t.read(); err != nil {
+		return err
+	}
+	if !opts.Follow && len(t.partial) > 0 {
+		t.line(string(t.partial))
+		t.partial = t.partial[:0]
+	}
+	t.flush()
+	for _, e := range last {
+		fn(e)
+	}
+	t.fn = fn
+	if !opts.Follow {
+		return nil
+	}
+
+	ticker := time.NewTicker(opts.Poll)
+	defer ticker.Stop()
+	lastData := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			t.flush()
+			return nil
+		case <-ticker.C:
+		}
+		n := t.offset
+		if err := t.read(); err != nil {
+			return err
+		}
+		if t.offset != n {
+			lastData = time.Now()
+			continue
+		}
+		// no new data: after a quiet Hold the pending entry is complete, and a
+		// rotation may have happened
+		if time.Since(lastData) >= opts.Hold {
+			t.flush()
+		}
+		if err := t.checkRotation(name); err != nil {
+			return err
+		}
+	}
+}
+
+// activePath returns the active file belonging to name.
+func activePath(name string) (string, error) {
+	files, err := List(name)
+	if err != nil {
+		return "", err
+	}
+	for _, f := range files {
+		if f.Active {
+			return f.Path, nil
+		}
+	}
+	return name, nil
+}
+
+type tailer struct {
+	f       *os.File
+	offset  int64
+	partial []byte
+	builder entryBuilder
+	filter  Filter
+	fn      func(Entry)
+}
+
+// read consumes the complete lines appended since the last call.
+func (t *tailer) read() error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := t.f.Read(buf)
+		t.offset += int64(n)
+		data := buf[:n]
+		for {
+			i := bytes.IndexByte(data, '\n')
+			if i < 0 {
+				break
+			}
+			t.partial = append(t.partial, data[:i]...)
+			t.line(string(t.partial))
+			t.partial = t.partial[:0]
+			data = data[i+1:]
+		}
+		t.partial = append(t.partial, data...)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (t *tailer) line(s string) {
+	if e, ok := t.builder.push(s); ok && t.filter.Match(e) {
+		t.fn(e)
+	}
+}
+
+func (t *tailer) flush() {
+	if e, ok := t.builder.flush(); ok && t.filter.Match(e) {
+		t.fn(e)
+	}
+}
+
+// checkRotation switches to the new active file after a rotation and rewinds
+// after a truncation.
+func (t *tailer) checkRotation(name string) error {
+	path, err := activePath(name)
+	if err != nil {
+		return err
+	}
+	cur, err := t.f.Stat()
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		// renamed away and not recreated yet
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if os.SameFile(cur, info) {
+		if info.Size() < t.offset {
+			t.offset, t.partial = 0, t.partial[:0]
+			_, err = t.f.Seek(0, io.SeekStart)
+		}
+		return err
+	}
+
+	// finish the old file before switching
+	if err := t.read(); err != nil {
+		return err
+	}
+	if len(t.partial) > 0 {
+		t.line(string(t.partial))
+		t.partial = t.partial[:0]
+	}
+	t.flush()
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	t.f.Close()
+	t.f, t.offset = f, 0
+	return nil
+}
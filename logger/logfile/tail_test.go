@@ -0,0 +1,171 @@
+package logfile
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func line(level, msg string) string {
+	return fmt.Sprintf("2024-03-10T12:00:00Z\t%s\tmain.go:1\t%s\n", level, msg)
+}
+
+func appendFile(t *testing.T, path, s string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(s)
+	f.Close()
+}
+
+func TestTailLines(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, name, line("INFO", "one")+line("ERROR", "two")+line("INFO", "three")+line("ERROR", "four"))
+
+	var got []string
+	err := Tail(context.Background(), name, TailOptions{Lines: 1, Filter: Filter{Level: "error"}}, func(e Entry) {
+		got = append(got, e.Message)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != "four" {
+		t.Errorf("Tail = %v, want [four]", got)
+	}
+}
+
+func TestTailFollowRotation(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	appendFile(t, name, line("INFO", "old"))
+
+	var mu sync.Mutex
+	var got []string
+	seen := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), got...)
+	}
+	waitFor := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for len(seen()) < n {
+			if time.Now().After(deadline) {
+				t.Fatalf("got %v, want %d entries", seen(), n)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- Tail(ctx, name, TailOptions{Follow: true, Poll: 5 * time.Millisecond}, func(e Entry) {
+			mu.Lock()
+			got = append(got, e.Message)
+			mu.Unlock()
+		})
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	appendFile(t, name, line("INFO", "a"))
+	waitFor(1)
+
+	// lumberjack style: rename the file away, then write a new one
+	appendFile(t, name, line("INFO", "b"))
+	os.Rename(name, filepath.Join(dir, "app-2024-03-10T12-00-00.000.log"))
+	appendFile(t, name, line("INFO", "c"))
+	waitFor(3)
+
+	// truncation
+	os.Truncate(name, 0)
+	time.Sleep(30 * time.Millisecond)
+	appendFile(t, name, line("INFO", "d"))
+	waitFor(4)
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if s := fmt.Sprint(seen()); s != "[a b c d]" {
+		t.Errorf("followed %s, want [a b c d]", s)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	gzPath := filepath.Join(dir, "app-2024-03-09T00-00-00.000.log.gz")
+	f, _ := os.Create(gzPath)
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte(line("ERROR", "archived")))
+	gz.Close()
+	f.Close()
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(gzPath, old, old)
+	appendFile(t, name, line("INFO", "current")+line("ERROR", "current error"))
+
+	var got []string
+	if err := Query(name, Filter{Level: "error"}, func(e Entry) bool {
+		got = append(got, e.Message)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if s := fmt.Sprint(got); s != "[archived current error]" {
+		t.Errorf("Query = %s", s)
+	}
+}
+
+func TestTailFollowSplitStacktrace(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, name, "")
+
+	var mu sync.Mutex
+	var got []Entry
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- Tail(ctx, name, TailOptions{Follow: true, Poll: 5 * time.Millisecond, Hold: time.Second}, func(e Entry) {
+			mu.Lock()
+			got = append(got, e)
+			mu.Unlock()
+		})
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	// the stacktrace arrives over several polls
+	appendFile(t, name, line("ERROR", "failed")+"main.main()\n")
+	time.Sleep(50 * time.Millisecond)
+	appendFile(t, name, "\t/src/main.go:10\n")
+	time.Sleep(50 * time.Millisecond)
+	appendFile(t, name, line("INFO", "next"))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		n := len(got)
+		mu.Unlock()
+		if n >= 1 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Message != "failed" || got[1].Message != "next" {
+		t.Fatalf("followed %+v, want failed and next", got)
+	}
+	if want := "main.main()\n\t/src/main.go:10"; got[0].Stack != want {
+		t.Errorf("stack = %q, want %q", got[0].Stack, want)
+	}
+}
@@ -5,89 +5,317 @@ import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"gopkg.in/natefinch/lumberjack.v2"
+	"io"
 	"log"
+	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 )
-/**
-代补充部分：
-1、更丰富的日志轮转，比如按天切割
-2、自定义日志模块初始化失败时，提供一个默认的日志
-*/
 
-// var defaultLogger *zap.Logger
-var customLogger *zap.Logger
+// global holds the *zap.Logger behind the package functions. Init and ReplaceCore
+// swap it while other goroutines may be logging.
+var global atomic.Value
+
+// customLogger returns the global logger.
+func customLogger() *zap.Logger {
+	return global.Load().(*zap.Logger)
+}
+
+// initMu serializes Init, ReplaceCore and Close, and guards current.
+var initMu sync.Mutex
+
+// current holds the outputs behind the global logger, closed when Init replaces them.
+var current pipeline
+
+// pipeline is what newCustomLogger builds besides the logger itself.
+type pipeline struct {
+	ws     zapcore.WriteSyncer
+	closer io.Closer
+	async  []*AsyncWriter
+}
 
 func init() {
-	//初始化customLogger
-	customLogger = newCustomLogger()
-	if customLogger != nil {
+	//初始化customLogger，环境变量LOG_*可覆盖默认配置
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		log.Printf("invalid log config from env, use default: %v", err)
+		cfg = DefaultConfig()
+	}
+	if err = Init(cfg); err == nil {
 		Info("log module init success!")
 		return
 	}
 
-	log.Panic("log module init failed, system exit!")
+	//自定义日志初始化失败时降级到stderr，不让引用方在import时崩溃
+	global.Store(newFallbackLogger())
+	current = pipeline{ws: zapcore.Lock(os.Stderr)}
+	Warnf("log module init failed, logging to stderr: %v", err)
+}
+
+// Init rebuilds the global logger from cfg. The previous log file is closed.
+// It is safe to call while other goroutines log, but loggers returned by With or
+// Module before Init keep the old outputs.
+func Init(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	l, p, err := newCustomLogger(cfg)
+	if err != nil {
+		return err
+	}
+	level, _ := cfg.zapLevel()
+	atomicLevel.SetLevel(level)
+	if err = setModuleLevels(cfg.ModuleLevels); err != nil {
+		return err
+	}
+
+	initMu.Lock()
+	defer initMu.Unlock()
+	old := current
+	global.Store(l)
+	current = p
+	if old.closer != nil {
+		_ = old.closer.Close()
+	}
+	return nil
+}
+
+// ReplaceCore makes the global logger write to core, still filtered by the global level,
+// and returns a function that restores the previous logger. It is meant for tests,
+// see logger/logtest; the outputs built by Init are left open.
+func ReplaceCore(core zapcore.Core) (restore func()) {
+	initMu.Lock()
+	defer initMu.Unlock()
+	prev := customLogger()
+	global.Store(zap.New(&levelCore{Core: withHooks(core, nil), enabled: atomicLevel.Enabled},
+		zap.AddCaller(), zap.AddCallerSkip(1)))
+	return func() {
+		global.Store(prev)
+	}
+}
+
+// Sync flushes any buffered log entries.
+func Sync() error {
+	return customLogger().Sync()
+}
+
+// Close flushes buffered entries and closes the log files. Call it at shutdown.
+// Without Async, later log calls reopen the files; with Async they are rejected.
+func Close() error {
+	_ = customLogger().Sync()
+	initMu.Lock()
+	defer initMu.Unlock()
+	if current.closer != nil {
+		return current.closer.Close()
+	}
+	return nil
+}
+
+// AsyncDropped returns how many entries the async writers dropped because their buffer was full.
+func AsyncDropped() uint64 {
+	initMu.Lock()
+	defer initMu.Unlock()
+	var n uint64
+	for _, w := range current.async {
+		n += w.Dropped()
+	}
+	return n
+}
+
+func Debugf(template string, args ...interface{}) {
+	customLogger().Debug(fmt.Sprintf(template, args...))
+}
+
+func Debug(msg string, fields ...Field) {
+	customLogger().Debug(msg, fields...)
+}
 
-	// fixme 提供一个默认的日志
-	//if customLogger == nil {
-	//	logger, err := zap.NewProduction()
-	//	if err != nil {
-	//		log.Panic("init zap SugaredLogger failed, error=", err)
-	//	}
-	//	customLogger = logger.WithOptions(zap.AddCallerSkip(1))
-	//}
+// Debugw logs msg with alternating keys and values.
+func Debugw(msg string, keysAndValues ...interface{}) {
+	customLogger().Debug(msg, sweeten(keysAndValues)...)
 }
 
 func Errorf(template string, args ...interface{}) {
-	customLogger.Error(fmt.Sprintf(template, args...))
+	customLogger().Error(fmt.Sprintf(template, args...))
+}
+
+func Error(msg string, fields ...Field) {
+	customLogger().Error(msg, fields...)
 }
 
-func Error(msg string) {
-	customLogger.Error(msg)
+// Errorw logs msg with alternating keys and values, e.g. Errorw("query failed", "table", t, "err", err).
+func Errorw(msg string, keysAndValues ...interface{}) {
+	customLogger().Error(msg, sweeten(keysAndValues)...)
 }
 
 func Infof(template string, args ...interface{}) {
-	customLogger.Info(fmt.Sprintf(template, args...))
+	customLogger().Info(fmt.Sprintf(template, args...))
 }
 
-func Info(msg string) {
-	customLogger.Info(msg)
+func Info(msg string, fields ...Field) {
+	customLogger().Info(msg, fields...)
+}
+
+// Infow logs msg with alternating keys and values.
+func Infow(msg string, keysAndValues ...interface{}) {
+	customLogger().Info(msg, sweeten(keysAndValues)...)
 }
 
 func Warnf(template string, args ...interface{}) {
-	customLogger.Warn(fmt.Sprintf(template, args...))
+	customLogger().Warn(fmt.Sprintf(template, args...))
+}
+
+func Warn(msg string, fields ...Field) {
+	customLogger().Warn(msg, fields...)
+}
+
+// Warnw logs msg with alternating keys and values.
+func Warnw(msg string, keysAndValues ...interface{}) {
+	customLogger().Warn(msg, sweeten(keysAndValues)...)
+}
+
+// Fatalf logs the message and then calls os.Exit(1).
+func Fatalf(template string, args ...interface{}) {
+	customLogger().Fatal(fmt.Sprintf(template, args...))
+}
+
+// Fatal logs the message and then calls os.Exit(1).
+func Fatal(msg string, fields ...Field) {
+	customLogger().Fatal(msg, fields...)
 }
 
-func Warn(msg string) {
-	customLogger.Warn(msg)
+// Fatalw logs msg with alternating keys and values and then calls os.Exit(1).
+func Fatalw(msg string, keysAndValues ...interface{}) {
+	customLogger().Fatal(msg, sweeten(keysAndValues)...)
 }
 
-func newCustomLogger() *zap.Logger {
-	return zap.New(zapcore.NewCore(getEncoder(), getWriteSyncer(), zapcore.InfoLevel)).WithOptions(zap.AddCaller(), zap.AddCallerSkip(1))
+// newCustomLogger builds one core per output at DebugLevel and leaves the global
+// filtering to atomicLevel, so the level can change at runtime and per module.
+func newCustomLogger(cfg Config) (*zap.Logger, pipeline, error) {
+	rules, err := cfg.Redact.compile()
+	if err != nil {
+		return nil, pipeline{}, err
+	}
+
+	var cores []zapcore.Core
+	var syncers []zapcore.WriteSyncer
+	var closers multiCloser
+	var async []*AsyncWriter
+	for _, o := range cfg.outputs() {
+		var ws zapcore.WriteSyncer
+		var closer io.Closer
+		var wrapEncoder func(zapcore.Encoder) zapcore.Encoder
+		var err error
+		if isSinkURL(o.Path) {
+			var sink Sink
+			sink, err = openSink(o)
+			ws, closer, wrapEncoder = sink.Writer, sink.Closer, sink.WrapEncoder
+		} else {
+			ws, closer, err = getWriteSyncer(o, cfg.Rotation)
+		}
+		if err != nil {
+			_ = closers.Close()
+			return nil, pipeline{}, err
+		}
+		// sinks already queue in the background, and AsyncWriter would hand them
+		// several entries in one Write
+		if cfg.Async.Enabled && !isSinkURL(o.Path) {
+			aw := NewAsyncWriter(ws, cfg.Async)
+			ws = aw
+			async = append(async, aw)
+			//先关闭异步写入器把缓冲刷到文件，再关闭文件
+			closers = append(closers, aw)
+		}
+		level := zapcore.DebugLevel
+		if o.Level != "" {
+			level, _ = parseLevel(o.Level)
+		}
+		var enc zapcore.Encoder = newRedactEncoder(getEncoder(o.encoder(), o.Color), rules)
+		if wrapEncoder != nil {
+			enc = wrapEncoder(enc)
+		}
+		cores = append(cores, zapcore.NewCore(enc, ws, level))
+		syncers = append(syncers, ws)
+		if closer != nil {
+			closers = append(closers, closer)
+		}
+	}
 
+	opts := []zap.Option{zap.AddCallerSkip(1)}
+	if cfg.Caller {
+		opts = append(opts, zap.AddCaller())
+	}
+	core := &levelCore{
+		Core:    withHooks(wrapSampling(zapcore.NewTee(cores...), cfg.Sampling), rules),
+		enabled: atomicLevel.Enabled,
+	}
+	p := pipeline{ws: zapcore.NewMultiWriteSyncer(syncers...), closer: closers, async: async}
+	return zap.New(core).WithOptions(opts...), p, nil
 }
 
-func getEncoder() zapcore.Encoder {
-	cfg := zap.NewProductionEncoderConfig()
+func getEncoder(encoder string, color bool) zapcore.Encoder {
+	encCfg := zap.NewProductionEncoderConfig()
 
-	cfg.EncodeTime = zapcore.TimeEncoderOfLayout(time.RFC3339)
-	cfg.EncodeLevel = zapcore.CapitalLevelEncoder
+	encCfg.EncodeTime = zapcore.TimeEncoderOfLayout(time.RFC3339)
+	encCfg.EncodeLevel = zapcore.CapitalLevelEncoder
 
-	return zapcore.NewConsoleEncoder(cfg)
+	if encoder == "json" {
+		return zapcore.NewJSONEncoder(encCfg)
+	}
+	if color {
+		encCfg.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	}
+	return zapcore.NewConsoleEncoder(encCfg)
 }
 
-// fixme 更丰富的日志轮转，比如按天切割
-func getWriteSyncer() zapcore.WriteSyncer {
+// getWriteSyncer 打开一个输出：stdout/stderr，或者日志文件。
+// 文件配置了Rotation.Interval时按时间切割，否则交给lumberjack按大小切割
+func getWriteSyncer(o OutputConfig, rotation RotationConfig) (zapcore.WriteSyncer, io.Closer, error) {
+	switch o.Path {
+	case "stdout":
+		return zapcore.Lock(os.Stdout), nil, nil
+	case "stderr":
+		return zapcore.Lock(os.Stderr), nil, nil
+	}
+	if o.Rotation != nil {
+		rotation = *o.Rotation
+	}
+
+	if rotation.Interval != "" {
+		rotator, err := NewTimeRotator(o.Path, rotation)
+		if err != nil {
+			return nil, nil, err
+		}
+		return newFallbackWriter(zapcore.AddSync(rotator)), rotator, nil
+	}
 	lumberJackLogger := &lumberjack.Logger{
-		Filename:  "./log/test.log",
-		MaxAge:    7,
-		MaxSize:   10,
-		LocalTime: true,
-		Compress:  true,
+		Filename:   o.Path,
+		MaxAge:     rotation.MaxAge,
+		MaxSize:    rotation.MaxSize,
+		MaxBackups: rotation.MaxBackups,
+		LocalTime:  rotation.LocalTime,
+		Compress:   rotation.Compress,
 	}
+	return newFallbackWriter(zapcore.AddSync(lumberJackLogger)), lumberJackLogger, nil
+}
+
+// multiCloser closes every closer and returns the first error.
+type multiCloser []io.Closer
 
-	return zapcore.AddSync(lumberJackLogger)
+func (m multiCloser) Close() error {
+	var first error
+	for _, c := range m {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
 }
 
+// GetWriter returns the writer used by the global logger.
 func GetWriter() zapcore.WriteSyncer {
-	return getWriteSyncer()
+	initMu.Lock()
+	defer initMu.Unlock()
+	return current.ws
 }
@@ -1,11 +1,15 @@
 package logger_test
 
 import (
-	"github.com/liuxiaodao666/go-util/logger"
 	"testing"
+
+	"github.com/liuxiaodao666/go-util/logger"
+	"github.com/liuxiaodao666/go-util/logger/logtest"
 )
 
 func TestLogPrint(t *testing.T) {
+	logs := logtest.Observe(t)
+
 	logger.Info("mock info")
 	logger.Info("mock info")
 	logger.Infof("mock %v", "info")
@@ -14,4 +18,11 @@ func TestLogPrint(t *testing.T) {
 	logger.Error("mock error")
 	logger.Errorf("mock %v", "error")
 
+	if n := len(logs.Level("info")); n != 3 {
+		t.Errorf("info entries = %d, want 3", n)
+	}
+	if n := len(logs.Message("mock warn")); n != 2 {
+		t.Errorf("warn entries = %d, want 2", n)
+	}
+	logs.AssertLogged(t, "error", "mock error")
 }
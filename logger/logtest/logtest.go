@@ -0,0 +1,124 @@
+// Package logtest swaps the global logger for an in-memory observer so tests can
+// assert on what was logged.
+package logtest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/liuxiaodao666/go-util/logger"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// Entry is one observed log entry.
+type Entry = observer.LoggedEntry
+
+// Logs holds the entries written while the observer is installed.
+type Logs struct {
+	logs *observer.ObservedLogs
+}
+
+// Observe installs the observer at debug level and restores the real logger
+// when the test finishes.
+func Observe(t testing.TB) *Logs {
+	t.Helper()
+	core, logs := observer.New(zapcore.DebugLevel)
+	restore := logger.ReplaceCore(core)
+	level := logger.GetLevel()
+	if err := logger.SetLevel("debug"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		restore()
+		_ = logger.SetLevel(level)
+	})
+	return &Logs{logs: logs}
+}
+
+// All returns every observed entry in order.
+func (l *Logs) All() []Entry {
+	return l.logs.All()
+}
+
+// Len returns the number of observed entries.
+func (l *Logs) Len() int {
+	return l.logs.Len()
+}
+
+// Reset discards the entries observed so far.
+func (l *Logs) Reset() {
+	l.logs.TakeAll()
+}
+
+// Level returns the entries at exactly level ("debug", "info", "warn", "error").
+func (l *Logs) Level(level string) []Entry {
+	var lvl zapcore.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil
+	}
+	return l.logs.FilterLevelExact(lvl).All()
+}
+
+// Message returns the entries whose message equals msg.
+func (l *Logs) Message(msg string) []Entry {
+	return l.logs.FilterMessage(msg).All()
+}
+
+// Contains returns the entries whose message contains snippet.
+func (l *Logs) Contains(snippet string) []Entry {
+	return l.logs.FilterMessageSnippet(snippet).All()
+}
+
+// Field returns the entries that have a field key with the given value,
+// compared by its fmt.Sprint form.
+func (l *Logs) Field(key string, value interface{}) []Entry {
+	want := fmt.Sprint(value)
+	return l.logs.Filter(func(e Entry) bool {
+		v, ok := e.ContextMap()[key]
+		return ok && fmt.Sprint(v) == want
+	}).All()
+}
+
+// AssertLogged fails the test unless an entry at level contains snippet in its message
+// and has every given field.
+func (l *Logs) AssertLogged(t testing.TB, level, snippet string, fields ...logger.Field) {
+	t.Helper()
+	for _, e := range l.Level(level) {
+		if strings.Contains(e.Message, snippet) && hasFields(e, fields) {
+			return
+		}
+	}
+	t.Errorf("no %s entry containing %q with fields %v; got:\n%s", level, snippet, fields, l.dump())
+}
+
+// AssertNotLogged fails the test if any entry at level contains snippet in its message.
+func (l *Logs) AssertNotLogged(t testing.TB, level, snippet string) {
+	t.Helper()
+	for _, e := range l.Level(level) {
+		if strings.Contains(e.Message, snippet) {
+			t.Errorf("unexpected %s entry %q", level, e.Message)
+		}
+	}
+}
+
+func hasFields(e Entry, fields []logger.Field) bool {
+	got := e.ContextMap()
+	for _, f := range fields {
+		enc := zapcore.NewMapObjectEncoder()
+		f.AddTo(enc)
+		if fmt.Sprint(got[f.Key]) != fmt.Sprint(enc.Fields[f.Key]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *Logs) dump() string {
+	var b strings.Builder
+	for _, e := range l.All() {
+		fmt.Fprintf(&b, "  %s %s %v\n", e.Level.CapitalString(), e.Message, e.ContextMap())
+	}
+	return b.String()
+}
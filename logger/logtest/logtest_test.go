@@ -0,0 +1,54 @@
+package logtest_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/liuxiaodao666/go-util/logger"
+	"github.com/liuxiaodao666/go-util/logger/logtest"
+)
+
+func TestObserve(t *testing.T) {
+	logs := logtest.Observe(t)
+
+	logger.Debug("debug message")
+	logger.Info("user login", logger.String("user", "alice"), logger.Int("attempt", 2))
+	logger.Module("db").Warnw("slow query", "ms", 250)
+	logger.Error("request failed", logger.Err(errors.New("boom")))
+
+	if logs.Len() != 4 {
+		t.Fatalf("Len() = %d, want 4", logs.Len())
+	}
+	logs.AssertLogged(t, "info", "login", logger.String("user", "alice"), logger.Int("attempt", 2))
+	logs.AssertLogged(t, "warn", "slow query", logger.Int("ms", 250))
+	logs.AssertLogged(t, "error", "failed", logger.String("error", "boom"))
+	logs.AssertNotLogged(t, "error", "login")
+
+	if got := logs.Field("user", "alice"); len(got) != 1 {
+		t.Errorf("Field(user) = %d entries, want 1", len(got))
+	}
+	if got := logs.Contains("query"); len(got) != 1 || got[0].LoggerName != "db" {
+		t.Errorf("Contains(query) = %+v", got)
+	}
+
+	logs.Reset()
+	if logs.Len() != 0 {
+		t.Errorf("Len() after Reset = %d", logs.Len())
+	}
+}
+
+func TestObserveRestoresLevel(t *testing.T) {
+	if err := logger.SetLevel("warn"); err != nil {
+		t.Fatal(err)
+	}
+	defer logger.SetLevel("info")
+
+	t.Run("observe", func(t *testing.T) {
+		logs := logtest.Observe(t)
+		logger.Debug("visible")
+		logs.AssertLogged(t, "debug", "visible")
+	})
+	if got := logger.GetLevel(); got != "warn" {
+		t.Errorf("level after Observe = %q, want warn", got)
+	}
+}
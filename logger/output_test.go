@@ -0,0 +1,61 @@
+package logger_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/liuxiaodao666/go-util/logger"
+)
+
+func TestMultipleOutputs(t *testing.T) {
+	dir := t.TempDir()
+	cfg := logger.DefaultConfig()
+	cfg.Level = "debug"
+	cfg.Outputs = []logger.OutputConfig{
+		{Path: filepath.Join(dir, "app.json"), Encoder: "json", Level: "info"},
+		{Path: filepath.Join(dir, "console.log"), Encoder: "console", Color: true},
+		{Path: filepath.Join(dir, "error.log"), Encoder: "console", Level: "error"},
+	}
+	if err := logger.Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { logger.Init(logger.DefaultConfig()) })
+
+	logger.Debug("debug entry")
+	logger.Info("info entry")
+	logger.Error("error entry")
+
+	entries := readJSONLines(t, filepath.Join(dir, "app.json"))
+	if len(entries) != 2 || entries[0]["msg"] != "info entry" {
+		t.Errorf("app.json = %v, want info and error entries", entries)
+	}
+
+	console := readFile(t, filepath.Join(dir, "console.log"))
+	if strings.Count(console, "\n") != 3 || !strings.Contains(console, "\x1b[") {
+		t.Errorf("console.log = %q, want three colored entries", console)
+	}
+
+	errors := readFile(t, filepath.Join(dir, "error.log"))
+	if strings.Count(errors, "\n") != 1 || !strings.Contains(errors, "error entry") {
+		t.Errorf("error.log = %q, want only the error entry", errors)
+	}
+}
+
+func TestOutputValidation(t *testing.T) {
+	cfg := logger.DefaultConfig()
+	cfg.Outputs = []logger.OutputConfig{{Path: "stderr", Level: "verbose"}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected an error for an unknown output level")
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
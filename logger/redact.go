@@ -0,0 +1,533 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// RedactConfig removes sensitive data from messages and fields before they are encoded.
+type RedactConfig struct {
+	// Fields are field names whose values are always replaced, compared case-insensitively,
+	// e.g. ["password", "token", "authorization"]. They also match keys nested in
+	// objects, arrays, maps and structs.
+	Fields []string `json:"fields" yaml:"fields" toml:"fields"`
+	// Patterns are regular expressions replaced in messages and in string values at any depth.
+	// The names "email", "bearer" and "card" select the built-in patterns.
+	Patterns []string `json:"patterns" yaml:"patterns" toml:"patterns"`
+	// Replacement is the text written instead of the secret. Default "[REDACTED]".
+	Replacement string `json:"replacement" yaml:"replacement" toml:"replacement"`
+}
+
+// builtinPatterns are the patterns selectable by name in RedactConfig.Patterns.
+var builtinPatterns = map[string]string{
+	"email":  `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`,
+	"bearer": `(?i)bearer\s+[A-Za-z0-9\-._~+/]+=*`,
+	"card":   `\b(?:\d[ -]?){12,18}\d\b`,
+}
+
+// Redactor rewrites a value before it is logged. key is the field name, or "" for the message.
+type Redactor func(key, value string) string
+
+var redactors struct {
+	sync.RWMutex
+	list []*Redactor
+}
+
+// RegisterRedactor adds a custom redactor applied after the configured rules.
+// It applies to every logger, including ones already built.
+// The returned function removes it again.
+func RegisterRedactor(r Redactor) (unregister func()) {
+	p := &r
+	redactors.Lock()
+	redactors.list = append(redactors.list, p)
+	redactors.Unlock()
+	return func() {
+		redactors.Lock()
+		for i, e := range redactors.list {
+			if e == p {
+				redactors.list = append(redactors.list[:i:i], redactors.list[i+1:]...)
+				break
+			}
+		}
+		redactors.Unlock()
+	}
+}
+
+// redactRules is the compiled form of RedactConfig.
+type redactRules struct {
+	fields      map[string]bool
+	patterns    []*regexp.Regexp
+	replacement string
+}
+
+func (c RedactConfig) compile() (*redactRules, error) {
+	r := &redactRules{fields: map[string]bool{}, replacement: c.Replacement}
+	if r.replacement == "" {
+		r.replacement = "[REDACTED]"
+	}
+	for _, f := range c.Fields {
+		r.fields[strings.ToLower(f)] = true
+	}
+	for _, p := range c.Patterns {
+		if builtin, ok := builtinPatterns[p]; ok {
+			p = builtin
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("logger: invalid redact pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// active reports whether any rule or redactor could change a value, so the
+// costlier rewrites of nested values can be skipped when none is configured.
+func (r *redactRules) active() bool {
+	if len(r.fields) > 0 || len(r.patterns) > 0 {
+		return true
+	}
+	redactors.RLock()
+	defer redactors.RUnlock()
+	return len(redactors.list) > 0
+}
+
+// denied reports whether the value of key is always replaced.
+func (r *redactRules) denied(key string) bool {
+	return key != "" && r.fields[strings.ToLower(key)]
+}
+
+// redact returns value with the secrets for key removed.
+func (r *redactRules) redact(key, value string) string {
+	if r.denied(key) {
+		return r.replacement
+	}
+	for _, re := range r.patterns {
+		value = re.ReplaceAllString(value, r.replacement)
+	}
+
+	redactors.RLock()
+	for _, fn := range redactors.list {
+		value = (*fn)(key, value)
+	}
+	redactors.RUnlock()
+	return value
+}
+
+// redactJSON applies the rules to a JSON document: the values of denied keys are
+// replaced at any depth and strings are redacted, keeping the key order.
+// key is the name the document is logged under.
+func (r *redactRules) redactJSON(key string, data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var out bytes.Buffer
+	if err := r.redactJSONValue(dec, key, &out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func (r *redactRules) redactJSONValue(dec *json.Decoder, key string, out *bytes.Buffer) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch v := tok.(type) {
+	case json.Delim:
+		if v == '[' {
+			out.WriteByte('[')
+			for i := 0; dec.More(); i++ {
+				if i > 0 {
+					out.WriteByte(',')
+				}
+				// array elements are redacted as values of the array's key
+				if err := r.redactJSONValue(dec, key, out); err != nil {
+					return err
+				}
+			}
+			out.WriteByte(']')
+			_, err = dec.Token()
+			return err
+		}
+		out.WriteByte('{')
+		for i := 0; dec.More(); i++ {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			k, _ := tok.(string)
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			writeJSONString(out, k)
+			out.WriteByte(':')
+			if r.denied(k) {
+				var skip json.RawMessage
+				if err := dec.Decode(&skip); err != nil {
+					return err
+				}
+				writeJSONString(out, r.replacement)
+				continue
+			}
+			if err := r.redactJSONValue(dec, k, out); err != nil {
+				return err
+			}
+		}
+		out.WriteByte('}')
+		_, err = dec.Token()
+		return err
+	case string:
+		writeJSONString(out, r.redact(key, v))
+	case json.Number:
+		out.WriteString(v.String())
+	case bool:
+		out.WriteString(strconv.FormatBool(v))
+	default:
+		out.WriteString("null")
+	}
+	return nil
+}
+
+func writeJSONString(out *bytes.Buffer, s string) {
+	b, _ := json.Marshal(s)
+	out.Write(b)
+}
+
+// redactReflected returns value redacted as JSON, and false when it is unchanged or
+// cannot be marshaled; the encoder then reports the marshal error itself.
+func (r *redactRules) redactReflected(key string, value interface{}) (json.RawMessage, bool) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, false
+	}
+	redacted, err := r.redactJSON(key, data)
+	if err != nil || bytes.Equal(redacted, data) {
+		return nil, false
+	}
+	return redacted, true
+}
+
+// redactEncoder applies redactRules to the message and to the fields passed to
+// EncodeEntry or added through With, including values nested in objects, arrays
+// and reflected values.
+type redactEncoder struct {
+	redactObjectEncoder
+	enc zapcore.Encoder
+}
+
+func newRedactEncoder(enc zapcore.Encoder, rules *redactRules) *redactEncoder {
+	return &redactEncoder{redactObjectEncoder: redactObjectEncoder{ObjectEncoder: enc, rules: rules}, enc: enc}
+}
+
+func (e *redactEncoder) Clone() zapcore.Encoder {
+	return newRedactEncoder(e.enc.Clone(), e.rules)
+}
+
+// EncodeEntry redacts the fields up front: the wrapped encoder adds them to its own
+// clone, bypassing the methods of redactEncoder.
+func (e *redactEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	ent.Message = e.rules.redact("", ent.Message)
+
+	var out []zapcore.Field
+	for i, f := range fields {
+		redacted, changed := e.redactField(f)
+		if changed && out == nil {
+			out = make([]zapcore.Field, len(fields))
+			copy(out, fields)
+		}
+		if out != nil {
+			out[i] = redacted
+		}
+	}
+	if out != nil {
+		fields = out
+	}
+	return e.enc.EncodeEntry(ent, fields)
+}
+
+func (e *redactEncoder) redactField(f zapcore.Field) (zapcore.Field, bool) {
+	r := e.rules
+	if r.denied(f.Key) {
+		return zapcore.Field{Key: f.Key, Type: zapcore.StringType, String: r.replacement}, true
+	}
+	switch f.Type {
+	case zapcore.StringType:
+		if v := r.redact(f.Key, f.String); v != f.String {
+			f.String = v
+			return f, true
+		}
+	case zapcore.ByteStringType:
+		s := string(f.Interface.([]byte))
+		if v := r.redact(f.Key, s); v != s {
+			return zapcore.Field{Key: f.Key, Type: zapcore.StringType, String: v}, true
+		}
+	case zapcore.ErrorType, zapcore.StringerType:
+		s := fieldString(f)
+		if v := r.redact(f.Key, s); v != s {
+			return zapcore.Field{Key: f.Key, Type: zapcore.StringType, String: v}, true
+		}
+	case zapcore.ReflectType:
+		if !r.active() {
+			break
+		}
+		if v, ok := r.redactReflected(f.Key, f.Interface); ok {
+			f.Interface = v
+			return f, true
+		}
+	case zapcore.ObjectMarshalerType, zapcore.InlineMarshalerType:
+		if r.active() {
+			f.Interface = redactObjectMarshaler{m: f.Interface.(zapcore.ObjectMarshaler), rules: r}
+			return f, true
+		}
+	case zapcore.ArrayMarshalerType:
+		if r.active() {
+			f.Interface = redactArrayMarshaler{m: f.Interface.(zapcore.ArrayMarshaler), rules: r, key: f.Key}
+			return f, true
+		}
+	}
+	return f, false
+}
+
+func fieldString(f zapcore.Field) (s string) {
+	defer func() {
+		if recover() != nil {
+			s = ""
+		}
+	}()
+	switch v := f.Interface.(type) {
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return ""
+}
+
+type redactObjectMarshaler struct {
+	m     zapcore.ObjectMarshaler
+	rules *redactRules
+}
+
+func (m redactObjectMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return m.m.MarshalLogObject(&redactObjectEncoder{ObjectEncoder: enc, rules: m.rules})
+}
+
+type redactArrayMarshaler struct {
+	m     zapcore.ArrayMarshaler
+	rules *redactRules
+	key   string
+}
+
+func (m redactArrayMarshaler) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	return m.m.MarshalLogArray(&redactArrayEncoder{ArrayEncoder: enc, rules: m.rules, key: m.key})
+}
+
+// redactObjectEncoder applies the rules to the values added to an object:
+// a denied key replaces the value whatever its type.
+type redactObjectEncoder struct {
+	zapcore.ObjectEncoder
+	rules *redactRules
+}
+
+// deny writes the replacement for a denied key and reports whether it did.
+func (e *redactObjectEncoder) deny(key string) bool {
+	if !e.rules.denied(key) {
+		return false
+	}
+	e.ObjectEncoder.AddString(key, e.rules.replacement)
+	return true
+}
+
+func (e *redactObjectEncoder) AddString(key, value string) {
+	e.ObjectEncoder.AddString(key, e.rules.redact(key, value))
+}
+
+func (e *redactObjectEncoder) AddByteString(key string, value []byte) {
+	e.ObjectEncoder.AddString(key, e.rules.redact(key, string(value)))
+}
+
+func (e *redactObjectEncoder) AddReflected(key string, value interface{}) error {
+	if e.deny(key) {
+		return nil
+	}
+	if e.rules.active() {
+		if v, ok := e.rules.redactReflected(key, value); ok {
+			return e.ObjectEncoder.AddReflected(key, v)
+		}
+	}
+	return e.ObjectEncoder.AddReflected(key, value)
+}
+
+func (e *redactObjectEncoder) AddObject(key string, m zapcore.ObjectMarshaler) error {
+	if e.deny(key) {
+		return nil
+	}
+	if !e.rules.active() {
+		return e.ObjectEncoder.AddObject(key, m)
+	}
+	return e.ObjectEncoder.AddObject(key, redactObjectMarshaler{m: m, rules: e.rules})
+}
+
+func (e *redactObjectEncoder) AddArray(key string, m zapcore.ArrayMarshaler) error {
+	if e.deny(key) {
+		return nil
+	}
+	if !e.rules.active() {
+		return e.ObjectEncoder.AddArray(key, m)
+	}
+	return e.ObjectEncoder.AddArray(key, redactArrayMarshaler{m: m, rules: e.rules, key: key})
+}
+
+func (e *redactObjectEncoder) AddBinary(key string, v []byte) {
+	if !e.deny(key) {
+		e.ObjectEncoder.AddBinary(key, v)
+	}
+}
+
+func (e *redactObjectEncoder) AddBool(key string, v bool) {
+	if !e.deny(key) {
+		e.ObjectEncoder.AddBool(key, v)
+	}
+}
+
+func (e *redactObjectEncoder) AddComplex128(key string, v complex128) {
+	if !e.deny(key) {
+		e.ObjectEncoder.AddComplex128(key, v)
+	}
+}
+
+func (e *redactObjectEncoder) AddComplex64(key string, v complex64) {
+	if !e.deny(key) {
+		e.ObjectEncoder.AddComplex64(key, v)
+	}
+}
+
+func (e *redactObjectEncoder) AddDuration(key string, v time.Duration) {
+	if !e.deny(key) {
+		e.ObjectEncoder.AddDuration(key, v)
+	}
+}
+
+func (e *redactObjectEncoder) AddFloat64(key string, v float64) {
+	if !e.deny(key) {
+		e.ObjectEncoder.AddFloat64(key, v)
+	}
+}
+
+func (e *redactObjectEncoder) AddFloat32(key string, v float32) {
+	if !e.deny(key) {
+		e.ObjectEncoder.AddFloat32(key, v)
+	}
+}
+
+func (e *redactObjectEncoder) AddInt(key string, v int) {
+	if !e.deny(key) {
+		e.ObjectEncoder.AddInt(key, v)
+	}
+}
+
+func (e *redactObjectEncoder) AddInt64(key string, v int64) {
+	if !e.deny(key) {
+		e.ObjectEncoder.AddInt64(key, v)
+	}
+}
+
+func (e *redactObjectEncoder) AddInt32(key string, v int32) {
+	if !e.deny(key) {
+		e.ObjectEncoder.AddInt32(key, v)
+	}
+}
+
+func (e *redactObjectEncoder) AddInt16(key string, v int16) {
+	if !e.deny(key) {
+		e.ObjectEncoder.AddInt16(key, v)
+	}
+}
+
+func (e *redactObjectEncoder) AddInt8(key string, v int8) {
+	if !e.deny(key) {
+		e.ObjectEncoder.AddInt8(key, v)
+	}
+}
+
+func (e *redactObjectEncoder) AddTime(key string, v time.Time) {
+	if !e.deny(key) {
+		e.ObjectEncoder.AddTime(key, v)
+	}
+}
+
+func (e *redactObjectEncoder) AddUint(key string, v uint) {
+	if !e.deny(key) {
+		e.ObjectEncoder.AddUint(key, v)
+	}
+}
+
+func (e *redactObjectEncoder) AddUint64(key string, v uint64) {
+	if !e.deny(key) {
+		e.ObjectEncoder.AddUint64(key, v)
+	}
+}
+
+func (e *redactObjectEncoder) AddUint32(key string, v uint32) {
+	if !e.deny(key) {
+		e.ObjectEncoder.AddUint32(key, v)
+	}
+}
+
+func (e *redactObjectEncoder) AddUint16(key string, v uint16) {
+	if !e.deny(key) {
+		e.ObjectEncoder.AddUint16(key, v)
+	}
+}
+
+func (e *redactObjectEncoder) AddUint8(key string, v uint8) {
+	if !e.deny(key) {
+		e.ObjectEncoder.AddUint8(key, v)
+	}
+}
+
+func (e *redactObjectEncoder) AddUintptr(key string, v uintptr) {
+	if !e.deny(key) {
+		e.ObjectEncoder.AddUintptr(key, v)
+	}
+}
+
+// redactArrayEncoder applies the rules to array elements, as values of the
+// key the array was added under.
+type redactArrayEncoder struct {
+	zapcore.ArrayEncoder
+	rules *redactRules
+	key   string
+}
+
+func (e *redactArrayEncoder) AppendString(v string) {
+	e.ArrayEncoder.AppendString(e.rules.redact(e.key, v))
+}
+
+func (e *redactArrayEncoder) AppendByteString(v []byte) {
+	e.ArrayEncoder.AppendString(e.rules.redact(e.key, string(v)))
+}
+
+func (e *redactArrayEncoder) AppendReflected(v interface{}) error {
+	if r, ok := e.rules.redactReflected(e.key, v); ok {
+		return e.ArrayEncoder.AppendReflected(r)
+	}
+	return e.ArrayEncoder.AppendReflected(v)
+}
+
+func (e *redactArrayEncoder) AppendObject(m zapcore.ObjectMarshaler) error {
+	return e.ArrayEncoder.AppendObject(redactObjectMarshaler{m: m, rules: e.rules})
+}
+
+func (e *redactArrayEncoder) AppendArray(m zapcore.ArrayMarshaler) error {
+	return e.ArrayEncoder.AppendArray(redactArrayMarshaler{m: m, rules: e.rules, key: e.key})
+}
@@ -0,0 +1,172 @@
+package logger_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/liuxiaodao666/go-util/logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestRedaction(t *testing.T) {
+	path := initJSONLoggerWith(t, func(cfg *logger.Config) {
+		cfg.Redact = logger.RedactConfig{
+			Fields:   []string{"password", "Authorization"},
+			Patterns: []string{"email", "bearer", "card"},
+		}
+	})
+	unregister := logger.RegisterRedactor(func(key, value string) string {
+		if key == "ssn" && len(value) >= 4 {
+			return "***-**-" + value[len(value)-4:]
+		}
+		return value
+	})
+	defer unregister()
+
+	logger.Infof("login from %s with header Bearer eyJhbGciOi.abc", "alice@example.com")
+	logger.With(logger.String("authorization", "Basic dXNlcjpwYXNz")).Info("request",
+		logger.String("password", "hunter2"),
+		logger.String("card", "4111 1111 1111 1111"),
+		logger.Err(errors.New("bad token for bob@example.org")),
+		logger.String("ssn", "123-45-6789"),
+		logger.Int("status", 401),
+	)
+
+	entries := readJSONLines(t, path)
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if msg := entries[0]["msg"].(string); msg != "login from [REDACTED] with header [REDACTED]" {
+		t.Errorf("msg = %q", msg)
+	}
+	e := entries[1]
+	for key, want := range map[string]interface{}{
+		"authorization": "[REDACTED]",
+		"password":      "[REDACTED]",
+		"card":          "[REDACTED]",
+		"error":         "bad token for [REDACTED]",
+		"ssn":           "***-**-6789",
+		"status":        float64(401),
+	} {
+		if e[key] != want {
+			t.Errorf("%s = %v, want %v", key, e[key], want)
+		}
+	}
+
+	raw := readFile(t, path)
+	for _, secret := range []string{"hunter2", "alice@example.com", "eyJhbGciOi", "dXNlcjpwYXNz"} {
+		if strings.Contains(raw, secret) {
+			t.Errorf("log file contains %q", secret)
+		}
+	}
+}
+
+type credentials struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Contact  string `json:"contact"`
+}
+
+// account marshals itself with nested objects and arrays.
+type account struct {
+	name  string
+	token string
+	mails []string
+}
+
+func (a account) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("name", a.name)
+	enc.AddString("token", a.token)
+	enc.AddInt("password", 1234)
+	_ = enc.AddArray("mails", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+		for _, m := range a.mails {
+			arr.AppendString(m)
+		}
+		return nil
+	}))
+	return enc.AddObject("session", zapcore.ObjectMarshalerFunc(func(o zapcore.ObjectEncoder) error {
+		o.AddString("Token", a.token)
+		return o.AddReflected("meta", map[string]string{"password": "nested", "note": "see " + a.mails[0]})
+	}))
+}
+
+func TestRedactionNested(t *testing.T) {
+	path := initJSONLoggerWith(t, func(cfg *logger.Config) {
+		cfg.Redact = logger.RedactConfig{
+			Fields:   []string{"password", "token"},
+			Patterns: []string{"email"},
+		}
+	})
+
+	payload := map[string]interface{}{
+		"user": "alice",
+		"auth": map[string]interface{}{"password": "hunter2", "token": []string{"t1", "t2"}},
+		"note": "mail alice@example.com",
+	}
+	acct := account{name: "bob", token: "s3cret", mails: []string{"bob@example.org"}}
+	logger.With(logger.Any("with", credentials{User: "carol", Password: "pw-with"})).Info("request",
+		logger.Any("payload", payload),
+		zap.Reflect("creds", credentials{User: "alice", Password: "pw-struct", Contact: "alice@example.com"}),
+		zap.ByteString("raw", []byte("from dave@example.net")),
+		zap.Object("account", acct),
+		zap.Array("accounts", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+			return arr.AppendObject(acct)
+		})),
+		zap.Inline(acct),
+		logger.Strings("cc", []string{"erin@example.com", "nobody"}),
+	)
+
+	raw := readFile(t, path)
+	for _, secret := range []string{"hunter2", "t1", "pw-struct", "pw-with", "s3cret", "1234", "nested",
+		"@example.com", "@example.org", "@example.net"} {
+		if strings.Contains(raw, secret) {
+			t.Errorf("log file contains %q: %s", secret, raw)
+		}
+	}
+
+	entries := readJSONLines(t, path)
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	e := entries[0]
+	// the structure and the harmless values survive, in their original order
+	creds, _ := json.Marshal(e["creds"])
+	if string(creds) != `{"contact":"[REDACTED]","password":"[REDACTED]","user":"alice"}` {
+		t.Errorf("creds = %s", creds)
+	}
+	if !strings.Contains(raw, `"creds":{"user":"alice","password":"[REDACTED]","contact":"[REDACTED]"}`) {
+		t.Errorf("creds not in field order: %s", raw)
+	}
+	auth := e["payload"].(map[string]interface{})["auth"].(map[string]interface{})
+	if auth["password"] != "[REDACTED]" || auth["token"] != "[REDACTED]" {
+		t.Errorf("payload.auth = %v", auth)
+	}
+	if e["raw"] != "from [REDACTED]" {
+		t.Errorf("raw = %v", e["raw"])
+	}
+	account := e["account"].(map[string]interface{})
+	if account["name"] != "bob" || account["password"] != "[REDACTED]" {
+		t.Errorf("account = %v", account)
+	}
+	if cc := e["cc"].([]interface{}); cc[0] != "[REDACTED]" || cc[1] != "nobody" {
+		t.Errorf("cc = %v", cc)
+	}
+}
+
+func TestRedactorUnregister(t *testing.T) {
+	path := initJSONLogger(t)
+	unregister := logger.RegisterRedactor(func(key, value string) string {
+		return strings.ReplaceAll(value, "secret", "***")
+	})
+	logger.Info("a secret")
+	unregister()
+	logger.Info("another secret")
+
+	entries := readJSONLines(t, path)
+	if len(entries) != 2 || entries[0]["msg"] != "a ***" || entries[1]["msg"] != "another secret" {
+		t.Errorf("entries = %v", entries)
+	}
+}
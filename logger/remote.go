@@ -0,0 +1,508 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// RemoteConfig tunes delivery for the remote outputs (syslog, http, tcp, udp).
+type RemoteConfig struct {
+	// BatchSize is the maximum number of entries sent at once. Default 100.
+	BatchSize int `json:"batch_size" yaml:"batch_size" toml:"batch_size"`
+	// FlushInterval sends a partial batch after this long, e.g. "1s". Default 1s.
+	FlushInterval string `json:"flush_interval" yaml:"flush_interval" toml:"flush_interval"`
+	// QueueSize is the number of entries waiting to be sent. Default 10000.
+	QueueSize int `json:"queue_size" yaml:"queue_size" toml:"queue_size"`
+	// Policy is what Write does when the queue is full: "drop" (default) or "block".
+	// Blocking makes every log call wait for the collector once the queue fills.
+	Policy string `json:"policy" yaml:"policy" toml:"policy"`
+	// MaxRetries is the number of extra attempts per batch. Default 3; negative disables retries.
+	MaxRetries int `json:"max_retries" yaml:"max_retries" toml:"max_retries"`
+	// RetryBackoff is the first delay between attempts, doubled each time, e.g. "100ms".
+	RetryBackoff string `json:"retry_backoff" yaml:"retry_backoff" toml:"retry_backoff"`
+	// Timeout bounds one connection attempt or request, e.g. "5s". Default 5s.
+	Timeout string `json:"timeout" yaml:"timeout" toml:"timeout"`
+	// SpoolDir keeps undelivered batches on disk while the collector is unreachable.
+	// Empty drops them instead.
+	SpoolDir string `json:"spool_dir" yaml:"spool_dir" toml:"spool_dir"`
+	// SpoolMaxSize is the maximum spool size in megabytes. Default 100.
+	SpoolMaxSize int `json:"spool_max_size" yaml:"spool_max_size" toml:"spool_max_size"`
+}
+
+func (c RemoteConfig) validate() error {
+	for name, v := range map[string]string{
+		"flush_interval": c.FlushInterval,
+		"retry_backoff":  c.RetryBackoff,
+		"timeout":        c.Timeout,
+	} {
+		if v == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			return fmt.Errorf("logger: invalid remote %s %q", name, v)
+		}
+	}
+	switch c.Policy {
+	case "", PolicyDrop, PolicyBlock:
+	default:
+		return fmt.Errorf("logger: unknown remote policy %q", c.Policy)
+	}
+	return nil
+}
+
+func durationOr(s string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
+
+func (c RemoteConfig) timeout() time.Duration { return durationOr(c.Timeout, 5*time.Second) }
+
+// Transport delivers a batch of encoded entries to a remote collector.
+type Transport interface {
+	Send(batch [][]byte) error
+	Close() error
+}
+
+// Sink is an output opened by a SinkFactory.
+type Sink struct {
+	Writer zapcore.WriteSyncer
+	Closer io.Closer
+	// WrapEncoder optionally wraps the output's encoder, e.g. to add a syslog header.
+	WrapEncoder func(zapcore.Encoder) zapcore.Encoder
+}
+
+// SinkFactory opens the output whose path is u.
+type SinkFactory func(u *url.URL, o OutputConfig) (Sink, error)
+
+var sinkFactories = struct {
+	sync.RWMutex
+	m map[string]SinkFactory
+}{m: map[string]SinkFactory{}}
+
+// RegisterSink makes outputs whose path starts with scheme:// use f.
+func RegisterSink(scheme string, f SinkFactory) {
+	sinkFactories.Lock()
+	sinkFactories.m[strings.ToLower(scheme)] = f
+	sinkFactories.Unlock()
+}
+
+func sinkFactory(scheme string) (SinkFactory, bool) {
+	sinkFactories.RLock()
+	defer sinkFactories.RUnlock()
+	f, ok := sinkFactories.m[strings.ToLower(scheme)]
+	return f, ok
+}
+
+// isSinkURL reports whether path names a registered sink rather than a file.
+func isSinkURL(path string) bool {
+	return strings.Contains(path, "://")
+}
+
+func openSink(o OutputConfig) (Sink, error) {
+	u, err := url.Parse(o.Path)
+	if err != nil {
+		return Sink{}, fmt.Errorf("logger: invalid output %q: %w", o.Path, err)
+	}
+	f, ok := sinkFactory(u.Scheme)
+	if !ok {
+		return Sink{}, fmt.Errorf("logger: no sink registered for %q", u.Scheme)
+	}
+	return f(u, o)
+}
+
+// RemoteWriter queues entries and sends them through a Transport in batches from a
+// background goroutine, retrying failures and spooling to disk while the collector
+// is unreachable.
+type RemoteWriter struct {
+	transport     Transport
+	batchSize     int
+	flushInterval time.Duration
+	maxRetries    int
+	backoff       time.Duration
+	block         bool
+	spool         *spool
+
+	queue   chan []byte
+	syncReq chan chan error
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+
+	// circuit breaker state, only used by the run goroutine
+	now       func() time.Time
+	cooldown  time.Duration
+	openUntil time.Time
+
+	sent    uint64
+	dropped uint64
+}
+
+// A batch that still fails after its retries opens the circuit breaker. While it is
+// open, batches skip the transport and go straight to the spool, or are dropped, so
+// a dead collector costs one failed attempt per cooldown instead of a retry loop per
+// batch. The cooldown starts at breakerMinCooldown and doubles up to breakerMaxCooldown
+// while the collector stays down; after it, one attempt without retries decides
+// whether the breaker closes. Sync always makes that single attempt.
+var (
+	breakerMinCooldown = time.Second
+	breakerMaxCooldown = time.Minute
+)
+
+var errBreakerOpen = errors.New("logger: remote collector unavailable, delivery paused")
+
+// NewRemoteWriter starts a RemoteWriter around t.
+func NewRemoteWriter(t Transport, name string, cfg RemoteConfig) (*RemoteWriter, error) {
+	w := &RemoteWriter{
+		transport:     t,
+		batchSize:     cfg.BatchSize,
+		flushInterval: durationOr(cfg.FlushInterval, time.Second),
+		maxRetries:    cfg.MaxRetries,
+		backoff:       durationOr(cfg.RetryBackoff, 100*time.Millisecond),
+		block:         cfg.Policy == PolicyBlock,
+		now:           time.Now,
+		syncReq:       make(chan chan error),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	if w.batchSize <= 0 {
+		w.batchSize = 100
+	}
+	switch {
+	case cfg.MaxRetries == 0:
+		w.maxRetries = 3
+	case cfg.MaxRetries < 0:
+		w.maxRetries = 0
+	}
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = 10000
+	}
+	w.queue = make(chan []byte, queueSize)
+
+	if cfg.SpoolDir != "" {
+		maxSize := cfg.SpoolMaxSize
+		if maxSize <= 0 {
+			maxSize = 100
+		}
+		s, err := openSpool(filepath.Join(cfg.SpoolDir, name+".spool"), int64(maxSize)*1024*1024)
+		if err != nil {
+			return nil, err
+		}
+		w.spool = s
+	}
+
+	go w.run()
+	return w, nil
+}
+
+// Write queues a copy of p. When the queue is full it blocks or drops, depending on the policy.
+func (w *RemoteWriter) Write(p []byte) (int, error) {
+	entry := append([]byte(nil), p...)
+	if !w.block {
+		select {
+		case w.queue <- entry:
+		default:
+			atomic.AddUint64(&w.dropped, 1)
+		}
+		return len(p), nil
+	}
+	select {
+	case w.queue <- entry:
+		return len(p), nil
+	case <-w.stop:
+		return 0, errors.New("logger: remote writer closed")
+	}
+}
+
+// Sync sends everything queued so far and reports the delivery error, if any.
+// Entries that could not be delivered are spooled when a spool is configured.
+func (w *RemoteWriter) Sync() error {
+	reply := make(chan error, 1)
+	select {
+	case w.syncReq <- reply:
+		return <-reply
+	case <-w.done:
+		return nil
+	}
+}
+
+// Close sends the remaining entries and closes the transport.
+func (w *RemoteWriter) Close() error {
+	w.once.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+	err := w.transport.Close()
+	if w.spool != nil {
+		if cerr := w.spool.close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Sent returns the number of entries delivered.
+func (w *RemoteWriter) Sent() uint64 { return atomic.LoadUint64(&w.sent) }
+
+// Dropped returns the number of entries lost because the queue or the spool was full,
+// or because delivery failed without a spool.
+func (w *RemoteWriter) Dropped() uint64 { return atomic.LoadUint64(&w.dropped) }
+
+func (w *RemoteWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	var batch [][]byte
+	for {
+		select {
+		case entry := <-w.queue:
+			batch = append(batch, entry)
+			if len(batch) >= w.batchSize {
+				w.deliver(batch, false)
+				batch = nil
+			}
+		case <-ticker.C:
+			w.deliver(batch, false)
+			batch = nil
+		case reply := <-w.syncReq:
+			batch = w.drainQueue(batch)
+			reply <- w.deliver(batch, true)
+			batch = nil
+		case <-w.stop:
+			batch = w.drainQueue(batch)
+			w.deliver(batch, false)
+			return
+		}
+	}
+}
+
+func (w *RemoteWriter) drainQueue(batch [][]byte) [][]byte {
+	for {
+		select {
+		case entry := <-w.queue:
+			batch = append(batch, entry)
+		default:
+			return batch
+		}
+	}
+}
+
+// deliver replays the spool first, so entries stay in order, then sends batch.
+// force makes a single attempt even while the circuit breaker is open.
+func (w *RemoteWriter) deliver(batch [][]byte, force bool) error {
+	tripped := w.cooldown > 0
+	if tripped && !force && w.now().Before(w.openUntil) {
+		w.toSpool(batch)
+		return errBreakerOpen
+	}
+	// after a failure, probe with a single attempt
+	retries := w.maxRetries
+	if tripped {
+		retries = 0
+	}
+
+	if w.spool != nil && !w.spool.empty() {
+		if err := w.replaySpool(); err != nil {
+			w.trip()
+			w.toSpool(batch)
+			return err
+		}
+		w.cooldown = 0
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+
+	var err error
+	for start := 0; start < len(batch); start += w.batchSize {
+		end := start + w.batchSize
+		if end > len(batch) {
+			end = len(batch)
+		}
+		if err = w.sendWithRetry(batch[start:end], retries); err != nil {
+			w.trip()
+			w.toSpool(batch[start:])
+			return err
+		}
+		w.cooldown = 0
+	}
+	return nil
+}
+
+// trip opens the circuit breaker, doubling the cooldown if it was already tripped.
+func (w *RemoteWriter) trip() {
+	switch {
+	case w.cooldown == 0:
+		w.cooldown = breakerMinCooldown
+	case w.cooldown < breakerMaxCooldown:
+		w.cooldown *= 2
+		if w.cooldown > breakerMaxCooldown {
+			w.cooldown = breakerMaxCooldown
+		}
+	}
+	w.openUntil = w.now().Add(w.cooldown)
+}
+
+func (w *RemoteWriter) sendWithRetry(batch [][]byte, retries int) error {
+	backoff := w.backoff
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-w.stop:
+				// shutting down: do not hold up Close with retries
+				return err
+			}
+			backoff *= 2
+		}
+		if err = w.transport.Send(batch); err == nil {
+			atomic.AddUint64(&w.sent, uint64(len(batch)))
+			return nil
+		}
+	}
+	return err
+}
+
+func (w *RemoteWriter) toSpool(batch [][]byte) {
+	if len(batch) == 0 {
+		return
+	}
+	if w.spool == nil {
+		atomic.AddUint64(&w.dropped, uint64(len(batch)))
+		return
+	}
+	atomic.AddUint64(&w.dropped, uint64(w.spool.append(batch)))
+}
+
+func (w *RemoteWriter) replaySpool() error {
+	entries, err := w.spool.read()
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(entries); start += w.batchSize {
+		end := start + w.batchSize
+		if end > len(entries) {
+			end = len(entries)
+		}
+		// a single attempt: the spool is retried on the next flush anyway
+		if err := w.transport.Send(entries[start:end]); err != nil {
+			if rerr := w.spool.rewrite(entries[start:]); rerr != nil {
+				return rerr
+			}
+			return err
+		}
+		atomic.AddUint64(&w.sent, uint64(end-start))
+	}
+	return w.spool.rewrite(nil)
+}
+
+// spool is an append-only file of length-prefixed entries.
+type spool struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	file    *os.File
+	size    int64
+}
+
+func openSpool(path string, maxSize int64) (*spool, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &spool{path: path, maxSize: maxSize, file: f, size: info.Size()}, nil
+}
+
+func (s *spool) empty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size == 0
+}
+
+// append writes entries to the spool and returns how many did not fit.
+func (s *spool) append(entries [][]byte) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w := bufio.NewWriter(s.file)
+	var header [4]byte
+	for i, e := range entries {
+		if s.size+int64(len(e))+4 > s.maxSize {
+			w.Flush()
+			return len(entries) - i
+		}
+		binary.BigEndian.PutUint32(header[:], uint32(len(e)))
+		w.Write(header[:])
+		w.Write(e)
+		s.size += int64(len(e)) + 4
+	}
+	if err := w.Flush(); err != nil {
+		return len(entries)
+	}
+	return 0
+}
+
+func (s *spool) read() ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	var entries [][]byte
+	for len(data) >= 4 {
+		n := int(binary.BigEndian.Uint32(data[:4]))
+		if len(data) < 4+n {
+			break // torn write at the end, drop it
+		}
+		entries = append(entries, data[4:4+n])
+		data = data[4+n:]
+	}
+	return entries, nil
+}
+
+// rewrite replaces the spool content with entries.
+func (s *spool) rewrite(entries [][]byte) error {
+	s.mu.Lock()
+	if err := s.file.Truncate(0); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.size = 0
+	s.mu.Unlock()
+	if dropped := s.append(entries); dropped > 0 {
+		return fmt.Errorf("logger: spool full, %d entries dropped", dropped)
+	}
+	return nil
+}
+
+func (s *spool) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
@@ -0,0 +1,421 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collector is a stand-in HTTP log collector.
+type collector struct {
+	mu    sync.Mutex
+	down  bool
+	lines []string
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	c.lines = append(c.lines, strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")...)
+}
+
+func (c *collector) received() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.lines...)
+}
+
+func TestHTTPSink(t *testing.T) {
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	cfg := DefaultConfig()
+	cfg.Outputs = []OutputConfig{{Path: srv.URL + "/ingest", Encoder: "json", Remote: RemoteConfig{FlushInterval: "1h"}}}
+	if err := Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer Init(DefaultConfig())
+
+	Info("shipped", String("service", "api"))
+	Errorf("failed %d", 3)
+	if err := Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := c.received()
+	if len(lines) != 2 {
+		t.Fatalf("collector got %q, want 2 lines", lines)
+	}
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil || entry["service"] != "api" {
+		t.Fatalf("line 0 = %q (%v)", lines[0], err)
+	}
+}
+
+func TestSinkEncoder(t *testing.T) {
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	cfg := DefaultConfig()
+	cfg.Outputs = []OutputConfig{{Path: srv.URL, Remote: RemoteConfig{FlushInterval: "1h"}}}
+	if err := Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer Init(DefaultConfig())
+
+	Info("shipped", String("service", "api"))
+	if err := Sync(); err != nil {
+		t.Fatal(err)
+	}
+	lines := c.received()
+	entry := map[string]interface{}{}
+	if len(lines) != 1 || json.Unmarshal([]byte(lines[0]), &entry) != nil || entry["service"] != "api" {
+		t.Fatalf("collector got %q, want one JSON line", lines)
+	}
+
+	for _, path := range []string{"http://localhost:1/ingest", "tcp://localhost:1", "udp://localhost:1"} {
+		cfg := DefaultConfig()
+		cfg.Outputs = []OutputConfig{{Path: path, Encoder: "console"}}
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: expected an error for the console encoder", path)
+		}
+	}
+	cfg = DefaultConfig()
+	cfg.Outputs = []OutputConfig{{Path: "syslog+udp://localhost:1", Encoder: "console"}}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("syslog+udp with the console encoder: %v", err)
+	}
+}
+
+func TestRemoteWriterSpool(t *testing.T) {
+	c := &collector{down: true}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	w, err := NewRemoteWriter(&httpTransport{url: srv.URL, client: srv.Client()}, "test", RemoteConfig{
+		FlushInterval: "1h",
+		MaxRetries:    1,
+		RetryBackoff:  "1ms",
+		SpoolDir:      t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	w.Write([]byte("a\n"))
+	w.Write([]byte("b\n"))
+	if err := w.Sync(); err == nil {
+		t.Fatal("Sync succeeded while the collector was down")
+	}
+	if w.spool.empty() {
+		t.Fatal("undelivered entries were not spooled")
+	}
+
+	c.mu.Lock()
+	c.down = false
+	c.mu.Unlock()
+	w.Write([]byte("c\n"))
+	if err := w.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(c.received(), ","); got != "a,b,c" {
+		t.Fatalf("collector got %s, want a,b,c", got)
+	}
+	if !w.spool.empty() || w.Sent() != 3 || w.Dropped() != 0 {
+		t.Fatalf("spool empty=%v sent=%d dropped=%d", w.spool.empty(), w.Sent(), w.Dropped())
+	}
+}
+
+func TestRemoteUnreachableDefaultConfig(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close() // nothing listens there any more
+
+	cfg := DefaultConfig()
+	cfg.Outputs = []OutputConfig{{Path: "http://" + addr + "/ingest", Encoder: "json"}}
+	if err := Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer Init(DefaultConfig())
+
+	// more than the default queue: the log calls must not wait for the collector
+	start := time.Now()
+	for i := 0; i < 25000; i++ {
+		Info("unreachable", Int("i", i))
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("logging took %v with an unreachable collector", d)
+	}
+}
+
+// failingTransport fails every Send until healthy is set.
+type failingTransport struct {
+	mu       sync.Mutex
+	healthy  bool
+	attempts int
+	sent     int
+}
+
+func (f *failingTransport) Send(batch [][]byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attempts++
+	if !f.healthy {
+		return errors.New("connection refused")
+	}
+	f.sent += len(batch)
+	return nil
+}
+
+func (f *failingTransport) Close() error { return nil }
+
+func (f *failingTransport) counts() (attempts, sent int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.attempts, f.sent
+}
+
+func TestRemoteWriterCircuitBreaker(t *testing.T) {
+	defer func(min time.Duration) { breakerMinCooldown = min }(breakerMinCooldown)
+	breakerMinCooldown = 200 * time.Millisecond
+
+	ft := &failingTransport{}
+	w, err := NewRemoteWriter(ft, "test", RemoteConfig{
+		FlushInterval: "1h",
+		BatchSize:     1,
+		MaxRetries:    2,
+		RetryBackoff:  "1ms",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	for i := 0; i < 10; i++ {
+		w.Write([]byte("entry\n"))
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for w.Dropped() < 10 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if w.Dropped() != 10 {
+		t.Fatalf("dropped = %d, want 10", w.Dropped())
+	}
+	// the first batch is tried 3 times, the others are dropped while the
+	// breaker is open instead of being retried too
+	if attempts, _ := ft.counts(); attempts != 3 {
+		t.Fatalf("attempts = %d, want 3", attempts)
+	}
+
+	// once the cooldown is over the next batch probes the collector, and a
+	// success closes the breaker
+	ft.mu.Lock()
+	ft.healthy = true
+	ft.mu.Unlock()
+	time.Sleep(300 * time.Millisecond)
+	w.Write([]byte("back\n"))
+	w.Write([]byte("again\n"))
+	if err := w.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if _, sent := ft.counts(); sent != 2 {
+		t.Fatalf("sent = %d, want 2", sent)
+	}
+}
+
+func TestSyslogUDPSink(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	cfg := DefaultConfig()
+	cfg.Outputs = []OutputConfig{{Path: "syslog+udp://" + pc.LocalAddr().String() + "?app=billing&facility=16"}}
+	if err := Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer Init(DefaultConfig())
+
+	Module("invoice").Warn("disk almost full")
+	if err := Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 2048)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(buf[:n])
+	// local0 (16) * 8 + warning (4)
+	if !strings.HasPrefix(msg, "<132>1 ") || !strings.Contains(msg, " billing ") ||
+		!strings.Contains(msg, " invoice - ") || !strings.HasSuffix(msg, "disk almost full") {
+		t.Fatalf("syslog message = %q", msg)
+	}
+}
+
+func TestTCPSinks(t *testing.T) {
+	readLine := func(r *bufio.Reader) (string, error) {
+		line, err := r.ReadString('\n')
+		return strings.TrimSuffix(line, "\n"), err
+	}
+	readOctetCounted := func(r *bufio.Reader) (string, error) {
+		size, err := r.ReadString(' ')
+		if err != nil {
+			return "", err
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(size))
+		if err != nil {
+			return "", err
+		}
+		msg := make([]byte, n)
+		_, err = io.ReadFull(r, msg)
+		return string(msg), err
+	}
+
+	for _, tc := range []struct {
+		scheme string
+		read   func(r *bufio.Reader) (string, error)
+		prefix string
+	}{
+		{"tcp", readLine, `{"level":"ERROR"`},
+		{"syslog+tcp", readOctetCounted, "<11>1 "},
+	} {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := make(chan string, 1)
+		go func() {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			if line, err := tc.read(bufio.NewReader(conn)); err == nil {
+				lines <- line
+			}
+		}()
+
+		cfg := DefaultConfig()
+		cfg.Outputs = []OutputConfig{{Path: tc.scheme + "://" + ln.Addr().String(), Encoder: "json"}}
+		if err := Init(cfg); err != nil {
+			t.Fatal(err)
+		}
+		Error("over tcp")
+		if err := Sync(); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case line := <-lines:
+			if !strings.HasPrefix(line, tc.prefix) || !strings.Contains(line, "over tcp") {
+				t.Errorf("%s: line = %q", tc.scheme, line)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: no line received", tc.scheme)
+		}
+		Init(DefaultConfig())
+		ln.Close()
+	}
+}
+
+func TestSinksWithAsync(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	frames := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			size, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(size))
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(r, msg); err != nil {
+				return
+			}
+			frames <- string(msg)
+		}
+	}()
+
+	cfg := DefaultConfig()
+	cfg.Async.Enabled = true
+	cfg.Outputs = []OutputConfig{
+		{Path: "syslog+tcp://" + ln.Addr().String()},
+		{Path: "syslog+udp://" + pc.LocalAddr().String()},
+	}
+	if err := Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer Init(DefaultConfig())
+
+	for i := 0; i < 3; i++ {
+		Infof("entry %d", i)
+	}
+	if err := Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	// one frame and one datagram per entry
+	for i := 0; i < 3; i++ {
+		select {
+		case f := <-frames:
+			if strings.Count(f, "<14>1 ") != 1 || !strings.HasSuffix(f, fmt.Sprintf("entry %d", i)) {
+				t.Errorf("tcp frame %d = %q", i, f)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("tcp frame %d not received", i)
+		}
+	}
+	buf := make([]byte, 2048)
+	for i := 0; i < 3; i++ {
+		pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := string(buf[:n]); strings.Count(d, "<14>1 ") != 1 || strings.Contains(d, "\n") {
+			t.Errorf("datagram %d = %q", i, d)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+func init() {
+	RegisterSink("http", newHTTPSink)
+	RegisterSink("https", newHTTPSink)
+	RegisterSink("tcp", newStreamSink)
+	RegisterSink("udp", newStreamSink)
+	RegisterSink("syslog+tcp", newSyslogSink)
+	RegisterSink("syslog+udp", newSyslogSink)
+}
+
+// sinkName turns a URL into a file-name-safe spool name.
+func sinkName(u *url.URL) string {
+	return strings.NewReplacer(":", "_", "/", "_", "+", "_").Replace(u.Scheme + "_" + u.Host + u.Path)
+}
+
+func remoteSink(t Transport, u *url.URL, o OutputConfig) (Sink, error) {
+	w, err := NewRemoteWriter(t, sinkName(u), o.Remote)
+	if err != nil {
+		t.Close()
+		return Sink{}, err
+	}
+	return Sink{Writer: w, Closer: w}, nil
+}
+
+// httpTransport POSTs each batch as JSON lines (application/x-ndjson).
+type httpTransport struct {
+	url    string
+	client *http.Client
+}
+
+func newHTTPSink(u *url.URL, o OutputConfig) (Sink, error) {
+	t := &httpTransport{url: u.String(), client: &http.Client{Timeout: o.Remote.timeout()}}
+	return remoteSink(t, u, o)
+}
+
+func (t *httpTransport) Send(batch [][]byte) error {
+	var body bytes.Buffer
+	for _, e := range batch {
+		body.Write(e)
+		if len(e) > 0 && e[len(e)-1] != '\n' {
+			body.WriteByte('\n')
+		}
+	}
+	resp, err := t.client.Post(t.url, "application/x-ndjson", &body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("logger: http sink: %s", resp.Status)
+	}
+	return nil
+}
+
+func (t *httpTransport) Close() error {
+	t.client.CloseIdleConnections()
+	return nil
+}
+
+// connTransport writes entries to a TCP or UDP connection, redialing after errors.
+// Over UDP every entry is one datagram. frame, when set, prefixes each entry on a stream.
+type connTransport struct {
+	network string
+	addr    string
+	timeout time.Duration
+	frame   func(entry []byte) []byte
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func newStreamSink(u *url.URL, o OutputConfig) (Sink, error) {
+	t := &connTransport{network: u.Scheme, addr: u.Host, timeout: o.Remote.timeout()}
+	return remoteSink(t, u, o)
+}
+
+func (t *connTransport) Send(batch [][]byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.conn == nil {
+		conn, err := net.DialTimeout(t.network, t.addr, t.timeout)
+		if err != nil {
+			return err
+		}
+		t.conn = conn
+	}
+	_ = t.conn.SetWriteDeadline(time.Now().Add(t.timeout))
+
+	var err error
+	if t.network == "udp" {
+		for _, e := range batch {
+			if _, err = t.conn.Write(bytes.TrimRight(e, "\n")); err != nil {
+				break
+			}
+		}
+	} else {
+		var buf bytes.Buffer
+		for _, e := range batch {
+			if t.frame != nil {
+				buf.Write(t.frame(e))
+				continue
+			}
+			buf.Write(e)
+		}
+		_, err = t.conn.Write(buf.Bytes())
+	}
+	if err != nil {
+		t.conn.Close()
+		t.conn = nil
+	}
+	return err
+}
+
+func (t *connTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.Close()
+	t.conn = nil
+	return err
+}
+
+// newSyslogSink sends RFC5424 messages. Query parameters: app (APP-NAME, default the
+// program name) and facility (0-23, default 1 "user").
+// Over TCP messages use octet-counting framing (RFC6587).
+func newSyslogSink(u *url.URL, o OutputConfig) (Sink, error) {
+	network := strings.TrimPrefix(u.Scheme, "syslog+")
+	facility := 1
+	if v := u.Query().Get("facility"); v != "" {
+		f, err := strconv.Atoi(v)
+		if err != nil || f < 0 || f > 23 {
+			return Sink{}, fmt.Errorf("logger: invalid syslog facility %q", v)
+		}
+		facility = f
+	}
+	app := u.Query().Get("app")
+	if app == "" {
+		app = filepath.Base(os.Args[0])
+	}
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "-"
+	}
+
+	t := &connTransport{network: network, addr: u.Host, timeout: o.Remote.timeout()}
+	if network == "tcp" {
+		t.frame = func(e []byte) []byte {
+			e = bytes.TrimRight(e, "\n")
+			return append([]byte(strconv.Itoa(len(e))+" "), e...)
+		}
+	}
+	sink, err := remoteSink(t, u, o)
+	if err != nil {
+		return sink, err
+	}
+	sink.WrapEncoder = func(enc zapcore.Encoder) zapcore.Encoder {
+		return &syslogEncoder{Encoder: enc, facility: facility, hostname: host, app: app, pid: os.Getpid()}
+	}
+	return sink, nil
+}
+
+// syslogEncoder prefixes every encoded entry with an RFC5424 header.
+type syslogEncoder struct {
+	zapcore.Encoder
+	facility int
+	hostname string
+	app      string
+	pid      int
+}
+
+func (e *syslogEncoder) Clone() zapcore.Encoder {
+	c := *e
+	c.Encoder = e.Encoder.Clone()
+	return &c
+}
+
+func (e *syslogEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	msg, err := e.Encoder.EncodeEntry(ent, fields)
+	if err != nil {
+		return nil, err
+	}
+	defer msg.Free()
+
+	msgID := ent.LoggerName
+	if msgID == "" {
+		msgID = "-"
+	}
+	out := bufferPool.Get()
+	fmt.Fprintf(out, "<%d>1 %s %s %s %d %s - ",
+		e.facility*8+syslogSeverity(ent.Level),
+		ent.Time.Format(time.RFC3339Nano),
+		e.hostname, e.app, e.pid, msgID)
+	out.Write(bytes.TrimRight(msg.Bytes(), "\n"))
+	out.AppendByte('\n')
+	return out, nil
+}
+
+var bufferPool = buffer.NewPool()
+
+func syslogSeverity(l zapcore.Level) int {
+	switch l {
+	case zapcore.DebugLevel:
+		return 7
+	case zapcore.InfoLevel:
+		return 6
+	case zapcore.WarnLevel:
+		return 4
+	case zapcore.ErrorLevel:
+		return 3
+	case zapcore.DPanicLevel:
+		return 2
+	case zapcore.PanicLevel:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,403 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/liuxiaodao666/go-util/logger/logfile"
+)
+
+const compressSuffix = ".gz"
+
+// TimeRotator is an io.WriteCloser that starts a new log file on calendar boundaries
+// (every Interval, aligned to local or UTC midnight) and, if MaxSize is set, whenever
+// the current file grows too large.
+type TimeRotator struct {
+	// Pattern is the file name with strftime-style placeholders:
+	// %Y %m %d %H %M %S, e.g. "./log/app-%Y-%m-%d.log".
+	Pattern string
+	// Interval is the rotation period, e.g. 24h for daily or 1h for hourly files.
+	Interval time.Duration
+	// MaxSize is the maximum size in megabytes of one file. Zero disables size rotation.
+	// Files rotated for size inside one period get a ".1", ".2"... suffix before the extension.
+	MaxSize int
+	// MaxAge is the maximum number of days to keep rotated files. Zero keeps all.
+	MaxAge int
+	// MaxBackups is the maximum number of rotated files to keep. Zero keeps all.
+	MaxBackups int
+	// LocalTime aligns periods and file names to local time instead of UTC.
+	LocalTime bool
+	// Compress gzips rotated files in the background.
+	Compress bool
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	period time.Time
+	seq    int
+	now    func() time.Time
+	// millCh wakes the compression goroutine, which closes millDone once
+	// Close has closed millCh; both are nil while no goroutine runs
+	millCh   chan struct{}
+	millDone chan struct{}
+}
+
+// NewTimeRotator returns a TimeRotator built from the rotation settings.
+// pattern defaults to filename with a date placeholder inserted before the extension.
+func NewTimeRotator(filename string, rc RotationConfig) (*TimeRotator, error) {
+	interval, err := parseInterval(rc.Interval)
+	if err != nil {
+		return nil, err
+	}
+	pattern := rc.Pattern
+	if pattern == "" {
+		pattern = defaultPattern(filename, interval)
+	}
+	return &TimeRotator{
+		Pattern:    pattern,
+		Interval:   interval,
+		MaxSize:    rc.MaxSize,
+		MaxAge:     rc.MaxAge,
+		MaxBackups: rc.MaxBackups,
+		LocalTime:  rc.LocalTime,
+		Compress:   rc.Compress,
+	}, nil
+}
+
+func parseInterval(s string) (time.Duration, error) {
+	switch strings.ToLower(s) {
+	case "daily", "day":
+		return 24 * time.Hour, nil
+	case "hourly", "hour":
+		return time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("logger: invalid rotation interval %q", s)
+	}
+	return d, nil
+}
+
+func defaultPattern(filename string, interval time.Duration) string {
+	layout := "%Y-%m-%d"
+	switch {
+	case interval < time.Hour:
+		layout = "%Y-%m-%d-%H%M"
+	case interval < 24*time.Hour:
+		layout = "%Y-%m-%d-%H"
+	}
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + "-" + layout + ext
+}
+
+// Write writes p to the current file, rotating first if a boundary was crossed.
+func (r *TimeRotator) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	period := r.periodOf(r.currentTime())
+	switch {
+	case r.file == nil:
+		if err := r.open(period, 0); err != nil {
+			return 0, err
+		}
+	case !period.Equal(r.period):
+		if err := r.rotate(period, 0); err != nil {
+			return 0, err
+		}
+	case r.MaxSize > 0 && r.size+int64(len(p)) > r.maxBytes() && r.size > 0:
+		if err := r.rotate(period, r.seq+1); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Sync commits the current file to stable storage.
+func (r *TimeRotator) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Sync()
+}
+
+// Close closes the current file and waits for background compression to finish.
+func (r *TimeRotator) Close() error {
+	r.mu.Lock()
+	err := r.closeFile()
+	ch, done := r.millCh, r.millDone
+	r.millCh, r.millDone = nil, nil
+	r.mu.Unlock()
+	if ch != nil {
+		// the goroutine still runs a pending request before it exits
+		close(ch)
+		<-done
+	}
+	return err
+}
+
+// Filename returns the path of the file currently written to.
+func (r *TimeRotator) Filename() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.filename(r.period, r.seq)
+}
+
+func (r *TimeRotator) currentTime() time.Time {
+	t := time.Now()
+	if r.now != nil {
+		t = r.now()
+	}
+	if r.LocalTime {
+		return t.Local()
+	}
+	return t.UTC()
+}
+
+func (r *TimeRotator) maxBytes() int64 {
+	return int64(r.MaxSize) * 1024 * 1024
+}
+
+// periodOf returns the start of the period containing t. Intervals up to a day
+// are aligned to midnight in t's location.
+func (r *TimeRotator) periodOf(t time.Time) time.Time {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+	if interval > 24*time.Hour {
+		return t.Truncate(interval)
+	}
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return midnight.Add(t.Sub(midnight) / interval * interval)
+}
+
+func (r *TimeRotator) filename(period time.Time, seq int) string {
+	name := formatPattern(r.Pattern, period)
+	if seq > 0 {
+		ext := filepath.Ext(name)
+		name = strings.TrimSuffix(name, ext) + "." + strconv.Itoa(seq) + ext
+	}
+	return name
+}
+
+func (r *TimeRotator) open(period time.Time, seq int) error {
+	for {
+		name := r.filename(period, seq)
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			return err
+		}
+		info, err := os.Stat(name)
+		if err == nil && r.MaxSize > 0 && info.Size() >= r.maxBytes() {
+			seq++
+			continue
+		}
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		r.file, r.period, r.seq, r.size = f, period, seq, 0
+		if info != nil {
+			r.size = info.Size()
+		}
+		return nil
+	}
+}
+
+func (r *TimeRotator) rotate(period time.Time, seq int) error {
+	if err := r.closeFile(); err != nil {
+		return err
+	}
+	if err := r.open(period, seq); err != nil {
+		return err
+	}
+	r.mill()
+	return nil
+}
+
+func (r *TimeRotator) closeFile() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// mill asks the background goroutine to compress and prune rotated files,
+// starting it if needed. r.mu must be held.
+func (r *TimeRotator) mill() {
+	if !r.Compress && r.MaxAge == 0 && r.MaxBackups == 0 {
+		return
+	}
+	if r.millCh == nil {
+		r.millCh, r.millDone = make(chan struct{}, 1), make(chan struct{})
+		go func(ch, done chan struct{}) {
+			defer close(done)
+			for range ch {
+				_ = r.millRun()
+			}
+		}(r.millCh, r.millDone)
+	}
+	select {
+	case r.millCh <- struct{}{}:
+	default:
+		// a run is already pending and will see this rotation too
+	}
+}
+
+// millRun compresses rotated files and removes those outside the retention limits.
+func (r *TimeRotator) millRun() error {
+	files, err := r.rotatedFiles()
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	if r.Compress {
+		for i, f := range files {
+			if strings.HasSuffix(f.path, compressSuffix) {
+				continue
+			}
+			if err := compressFile(f.path); err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			files[i].path += compressSuffix
+		}
+	}
+
+	var remove []rotatedFile
+	if r.MaxBackups > 0 && len(files) > r.MaxBackups {
+		remove = append(remove, files[r.MaxBackups:]...)
+		files = files[:r.MaxBackups]
+	}
+	if r.MaxAge > 0 {
+		cutoff := r.currentTime().Add(-time.Duration(r.MaxAge) * 24 * time.Hour)
+		for _, f := range files {
+			if f.modTime.Before(cutoff) {
+				remove = append(remove, f)
+			}
+		}
+	}
+	for _, f := range remove {
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("logger: rotate: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+type rotatedFile struct {
+	path    string
+	modTime time.Time
+}
+
+// rotatedFiles lists files matching Pattern, newest first, excluding the active file.
+func (r *TimeRotator) rotatedFiles() ([]rotatedFile, error) {
+	r.mu.Lock()
+	current := filepath.Clean(r.filename(r.period, r.seq))
+	r.mu.Unlock()
+
+	glob := patternGlob(r.Pattern)
+	ext := filepath.Ext(glob)
+	matches, err := filepath.Glob(strings.TrimSuffix(glob, ext) + "*" + ext + "*")
+	if err != nil {
+		return nil, err
+	}
+	// the glob also matches other outputs sharing the prefix, e.g. app-worker-%Y.log
+	re := logfile.NameRegexp(r.Pattern)
+
+	var files []rotatedFile
+	for _, m := range matches {
+		if filepath.Clean(m) == current || !re.MatchString(filepath.Base(m)) {
+			continue
+		}
+		info, err := os.Stat(m)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		files = append(files, rotatedFile{path: m, modTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.After(files[j].modTime)
+	})
+	return files, nil
+}
+
+// formatPattern replaces the strftime-style placeholders in pattern with t.
+func formatPattern(pattern string, t time.Time) string {
+	return strings.NewReplacer(
+		"%Y", fmt.Sprintf("%04d", t.Year()),
+		"%m", fmt.Sprintf("%02d", int(t.Month())),
+		"%d", fmt.Sprintf("%02d", t.Day()),
+		"%H", fmt.Sprintf("%02d", t.Hour()),
+		"%M", fmt.Sprintf("%02d", t.Minute()),
+		"%S", fmt.Sprintf("%02d", t.Second()),
+		"%%", "%",
+	).Replace(pattern)
+}
+
+// patternGlob turns pattern into a glob matching any period.
+func patternGlob(pattern string) string {
+	return strings.NewReplacer(
+		"%Y", "*", "%m", "*", "%d", "*", "%H", "*", "%M", "*", "%S", "*", "%%", "%",
+	).Replace(pattern)
+}
+
+func compressFile(name string) (err error) {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(name+compressSuffix, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			dst.Close()
+			os.Remove(name + compressSuffix)
+		}
+	}()
+
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err != nil {
+		return err
+	}
+	if err = gz.Close(); err != nil {
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+	// keep the original time so retention by age still works
+	if err = os.Chtimes(name+compressSuffix, info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+	src.Close()
+	return os.Remove(name)
+}
@@ -0,0 +1,186 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestTimeRotatorDaily(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 18, 23, 59, 0, 0, time.UTC)
+	r := &TimeRotator{
+		Pattern:  filepath.Join(dir, "app-%Y-%m-%d.log"),
+		Interval: 24 * time.Hour,
+		now:      func() time.Time { return now },
+	}
+
+	write(t, r, "first\n")
+	now = now.Add(2 * time.Minute)
+	write(t, r, "second\n")
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertFiles(t, dir, "app-2026-10-18.log", "app-2026-10-19.log")
+	assertContent(t, filepath.Join(dir, "app-2026-10-19.log"), "second\n")
+}
+
+func TestTimeRotatorSizeAndCompress(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	r := &TimeRotator{
+		Pattern:    filepath.Join(dir, "app-%Y-%m-%d-%H.log"),
+		Interval:   time.Hour,
+		MaxSize:    1,
+		MaxBackups: 2,
+		Compress:   true,
+		now:        func() time.Time { return now },
+	}
+
+	chunk := string(make([]byte, 600*1024))
+	write(t, r, chunk)
+	write(t, r, chunk) // exceeds 1MB: app-...-10.1.log
+	now = now.Add(time.Hour)
+	write(t, r, "next hour\n")
+	now = now.Add(time.Hour)
+	write(t, r, "hour after\n")
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertFiles(t, dir,
+		"app-2026-10-18-10.1.log.gz",
+		"app-2026-10-18-11.log.gz",
+		"app-2026-10-18-12.log",
+	)
+}
+
+func TestTimeRotatorCloseStopsMill(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for i := 0; i < 5; i++ {
+		dir := t.TempDir()
+		now := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+		r := &TimeRotator{
+			Pattern:  filepath.Join(dir, "app-%Y-%m-%d-%H.log"),
+			Interval: time.Hour,
+			Compress: true,
+			now:      func() time.Time { return now },
+		}
+		write(t, r, "first\n")
+		now = now.Add(time.Hour)
+		write(t, r, "second\n")
+		if err := r.Close(); err != nil {
+			t.Fatal(err)
+		}
+		// still usable after Close, like lumberjack, with a new goroutine
+		write(t, r, "third\n")
+		now = now.Add(time.Hour)
+		write(t, r, "fourth\n")
+		if err := r.Close(); err != nil {
+			t.Fatal(err)
+		}
+		assertFiles(t, dir, "app-2026-10-18-10.log.gz", "app-2026-10-18-11.log.gz", "app-2026-10-18-12.log")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutines = %d after closing the rotators, want %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestTimeRotatorKeepsSiblingFiles(t *testing.T) {
+	dir := t.TempDir()
+	// other outputs and files sharing the prefix of the pattern
+	siblings := []string{"app-worker-2026-10-01.log", "app-2026-10-01.log.bak", "app-2026-10-01-error.log"}
+	for _, name := range siblings {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("other\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	r := &TimeRotator{
+		Pattern:    filepath.Join(dir, "app-%Y-%m-%d.log"),
+		Interval:   24 * time.Hour,
+		MaxBackups: 1,
+		now:        func() time.Time { return now },
+	}
+	for i := 0; i < 3; i++ {
+		write(t, r, "entry\n")
+		now = now.Add(24 * time.Hour)
+	}
+	write(t, r, "entry\n")
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertFiles(t, dir, append([]string{"app-2026-10-20.log", "app-2026-10-21.log"}, siblings...)...)
+}
+
+func TestTimeRotatorPeriodOf(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	r := &TimeRotator{Interval: 15 * time.Minute}
+	got := r.periodOf(time.Date(2026, 10, 18, 7, 44, 59, 0, loc))
+	want := time.Date(2026, 10, 18, 7, 30, 0, 0, loc)
+	if !got.Equal(want) {
+		t.Errorf("periodOf = %v, want %v", got, want)
+	}
+}
+
+func TestDefaultPattern(t *testing.T) {
+	cases := map[time.Duration]string{
+		24 * time.Hour:   "./log/app-%Y-%m-%d.log",
+		time.Hour:        "./log/app-%Y-%m-%d-%H.log",
+		10 * time.Minute: "./log/app-%Y-%m-%d-%H%M.log",
+	}
+	for interval, want := range cases {
+		if got := defaultPattern("./log/app.log", interval); got != want {
+			t.Errorf("defaultPattern(%v) = %q, want %q", interval, got, want)
+		}
+	}
+}
+
+func write(t *testing.T, r *TimeRotator, s string) {
+	t.Helper()
+	if _, err := r.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertFiles(t *testing.T, dir string, want ...string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	sort.Strings(want)
+	if len(got) != len(want) {
+		t.Fatalf("files = %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("files = %v, want %v", got, want)
+		}
+	}
+}
+
+func assertContent(t *testing.T, path, want string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != want {
+		t.Errorf("%s = %q, want %q", path, data, want)
+	}
+}
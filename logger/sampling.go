@@ -0,0 +1,203 @@
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// SamplingConfig limits repeated messages, keyed by message and level.
+type SamplingConfig struct {
+	// Initial is the number of entries logged per Interval for each key before sampling
+	// starts. Zero disables sampling.
+	Initial int `json:"initial" yaml:"initial" toml:"initial"`
+	// Thereafter logs every Thereafter-th entry once Initial is reached. Zero drops them all.
+	Thereafter int `json:"thereafter" yaml:"thereafter" toml:"thereafter"`
+	// Interval is the sampling window, e.g. "1s". Default 1s.
+	Interval string `json:"interval" yaml:"interval" toml:"interval"`
+	// Dedup collapses consecutive identical entries, with the same level, logger,
+	// message and fields, into one "message repeated X times" summary, written when
+	// a different entry arrives or the Interval ends.
+	Dedup bool `json:"dedup" yaml:"dedup" toml:"dedup"`
+}
+
+func (c SamplingConfig) interval() time.Duration {
+	if d, err := time.ParseDuration(c.Interval); err == nil && d > 0 {
+		return d
+	}
+	return time.Second
+}
+
+func (c SamplingConfig) validate() error {
+	if c.Interval != "" {
+		if d, err := time.ParseDuration(c.Interval); err != nil || d <= 0 {
+			return fmt.Errorf("logger: invalid sampling interval %q", c.Interval)
+		}
+	}
+	if c.Initial < 0 || c.Thereafter < 0 {
+		return fmt.Errorf("logger: sampling counts must not be negative")
+	}
+	return nil
+}
+
+// suppressed counts entries dropped by sampling or deduplication.
+var suppressed uint64
+
+// Suppressed returns the number of entries dropped by sampling or deduplication.
+func Suppressed() uint64 {
+	return atomic.LoadUint64(&suppressed)
+}
+
+// wrapSampling applies the sampler and the deduplicator to core as configured.
+func wrapSampling(core zapcore.Core, cfg SamplingConfig) zapcore.Core {
+	if cfg.Initial > 0 {
+		core = zapcore.NewSamplerWithOptions(core, cfg.interval(), cfg.Initial, cfg.Thereafter,
+			zapcore.SamplerHook(func(_ zapcore.Entry, dec zapcore.SamplingDecision) {
+				if dec&zapcore.LogDropped > 0 {
+					atomic.AddUint64(&suppressed, 1)
+				}
+			}))
+	}
+	if cfg.Dedup {
+		core = newDedupCore(core, cfg.interval())
+	}
+	return core
+}
+
+// dedupCore suppresses an entry identical to the previous one written through the
+// same core: same level, logger name, message and fields. When a different entry
+// arrives, the Interval ends or Sync is called, it writes a single
+// "message repeated X times" summary carrying the fields of the repeated entry.
+// Each With child has its own state.
+type dedupCore struct {
+	zapcore.Core
+	window time.Duration
+	// keyEnc encodes the fields of an entry into its key; it holds the With fields.
+	keyEnc zapcore.Encoder
+
+	mu         sync.Mutex
+	lastKey    string
+	last       zapcore.Entry
+	lastFields []zapcore.Field
+	since      time.Time
+	repeats    int
+	timer      *time.Timer
+}
+
+func newDedupCore(core zapcore.Core, window time.Duration) *dedupCore {
+	return &dedupCore{Core: core, window: window, keyEnc: zapcore.NewJSONEncoder(zapcore.EncoderConfig{})}
+}
+
+func (c *dedupCore) With(fields []zapcore.Field) zapcore.Core {
+	child := newDedupCore(c.Core.With(fields), c.window)
+	child.keyEnc = c.keyEnc.Clone()
+	for _, f := range fields {
+		f.AddTo(child.keyEnc)
+	}
+	return child
+}
+
+// Check defers the decision to Write, the first place the call's fields are known.
+func (c *dedupCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *dedupCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	key := c.key(ent, fields)
+
+	c.mu.Lock()
+	if key == c.lastKey && !c.last.Time.IsZero() && ent.Time.Sub(c.since) < c.window {
+		c.repeats++
+		if c.timer == nil {
+			c.timer = time.AfterFunc(c.since.Add(c.window).Sub(ent.Time), c.flush)
+		}
+		c.mu.Unlock()
+		atomic.AddUint64(&suppressed, 1)
+		return nil
+	}
+	summary, summaryFields, ok := c.takeSummary(ent.Time)
+	c.lastKey, c.last, c.since = key, ent, ent.Time
+	c.lastFields = append(c.lastFields[:0:0], fields...)
+	c.mu.Unlock()
+
+	if ok {
+		c.write(summary, summaryFields)
+	}
+	c.write(ent, fields)
+	return nil
+}
+
+// key identifies entries that count as repeats of each other.
+func (c *dedupCore) key(ent zapcore.Entry, fields []zapcore.Field) string {
+	buf, err := c.keyEnc.EncodeEntry(zapcore.Entry{}, fields)
+	if err != nil {
+		// unencodable fields never match, so nothing is lost
+		return ""
+	}
+	defer buf.Free()
+	return ent.Level.String() + "\x00" + ent.LoggerName + "\x00" + ent.Message + "\x00" + buf.String()
+}
+
+// flush writes the summary when the window of the repeated entry ends. A later
+// identical entry then starts a new window and is logged again.
+func (c *dedupCore) flush() {
+	c.mu.Lock()
+	c.timer = nil
+	summary, fields, ok := c.takeSummary(time.Now())
+	c.lastKey, c.last = "", zapcore.Entry{}
+	c.mu.Unlock()
+
+	if ok {
+		c.write(summary, fields)
+	}
+}
+
+func (c *dedupCore) Sync() error {
+	c.mu.Lock()
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	summary, fields, ok := c.takeSummary(time.Now())
+	c.lastKey, c.last = "", zapcore.Entry{}
+	c.mu.Unlock()
+
+	if ok {
+		c.write(summary, fields)
+	}
+	return c.Core.Sync()
+}
+
+// takeSummary returns the summary entry for the pending repeats, if any. c.mu must be held.
+func (c *dedupCore) takeSummary(now time.Time) (zapcore.Entry, []zapcore.Field, bool) {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	if c.repeats == 0 {
+		return zapcore.Entry{}, nil, false
+	}
+	summary := c.last
+	summary.Time = now
+	times := "times"
+	if c.repeats == 1 {
+		times = "time"
+	}
+	summary.Message = fmt.Sprintf("message repeated %d %s: %s", c.repeats, times, c.last.Message)
+	c.repeats = 0
+	return summary, c.lastFields, true
+}
+
+// write passes ent through the wrapped core's Check, so its level filters and
+// sampling still apply.
+func (c *dedupCore) write(ent zapcore.Entry, fields []zapcore.Field) {
+	if ce := c.Core.Check(ent, nil); ce != nil {
+		ce.Write(fields...)
+	}
+}
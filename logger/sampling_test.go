@@ -0,0 +1,138 @@
+package logger_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/liuxiaodao666/go-util/logger"
+)
+
+func TestSampling(t *testing.T) {
+	path := initJSONLoggerWith(t, func(cfg *logger.Config) {
+		cfg.Sampling = logger.SamplingConfig{Initial: 3, Thereafter: 10, Interval: "1h"}
+	})
+	before := logger.Suppressed()
+
+	for i := 0; i < 25; i++ {
+		logger.Errorf("Error executing job: %v", "timeout")
+		logger.Info("other")
+	}
+
+	// 3 initial + the 13th and 23rd for each of the two messages.
+	entries := readJSONLines(t, path)
+	if len(entries) != 10 {
+		t.Fatalf("got %d entries, want 10", len(entries))
+	}
+	if got := logger.Suppressed() - before; got != 40 {
+		t.Errorf("suppressed = %d, want 40", got)
+	}
+}
+
+func TestDedup(t *testing.T) {
+	path := initJSONLoggerWith(t, func(cfg *logger.Config) {
+		cfg.Sampling = logger.SamplingConfig{Dedup: true, Interval: "1h"}
+	})
+
+	for i := 0; i < 5; i++ {
+		logger.Error("connection refused")
+	}
+	logger.Info("recovered")
+	logger.Error("connection refused")
+	logger.Error("connection refused")
+
+	entries := readJSONLines(t, path)
+	var msgs []string
+	for _, e := range entries {
+		msgs = append(msgs, e["msg"].(string))
+	}
+	want := []string{
+		"connection refused",
+		"message repeated 4 times: connection refused",
+		"recovered",
+		"connection refused",
+		"message repeated 1 time: connection refused",
+	}
+	if len(msgs) != len(want) {
+		t.Fatalf("messages = %q, want %q", msgs, want)
+	}
+	for i := range want {
+		if msgs[i] != want[i] {
+			t.Fatalf("messages = %q, want %q", msgs, want)
+		}
+	}
+	if entries[1]["level"] != "ERROR" {
+		t.Errorf("summary level = %v, want ERROR", entries[1]["level"])
+	}
+}
+
+func TestDedupKeepsDistinctFields(t *testing.T) {
+	path := initJSONLoggerWith(t, func(cfg *logger.Config) {
+		cfg.Sampling = logger.SamplingConfig{Dedup: true, Interval: "1h"}
+	})
+
+	for id := 1; id <= 3; id++ {
+		logger.Error("job failed", logger.Int("job_id", id))
+	}
+	logger.Error("job failed", logger.Int("job_id", 3))
+	logger.Module("worker").Error("job failed", logger.Int("job_id", 3))
+	// a With child does not share the state of its parent
+	child := logger.With(logger.String("trace_id", "t1"))
+	child.Error("job failed", logger.Int("job_id", 3))
+
+	entries := readJSONLines(t, path)
+	type entry struct {
+		msg    string
+		logger interface{}
+		job    float64
+	}
+	want := []entry{
+		{"job failed", nil, 1},
+		{"job failed", nil, 2},
+		{"job failed", nil, 3},
+		{"message repeated 1 time: job failed", nil, 3},
+		{"job failed", "worker", 3},
+		{"job failed", nil, 3},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("entries = %v, want %d", entries, len(want))
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e["msg"] != w.msg || e["logger"] != w.logger || e["job_id"] != w.job {
+			t.Errorf("entry %d = %v, want %+v", i, e, w)
+		}
+	}
+	if entries[5]["trace_id"] != "t1" {
+		t.Errorf("child entry = %v, want trace_id", entries[5])
+	}
+}
+
+func TestDedupSummaryAfterInterval(t *testing.T) {
+	path := initJSONLoggerWith(t, func(cfg *logger.Config) {
+		cfg.Sampling = logger.SamplingConfig{Dedup: true, Interval: "50ms"}
+	})
+
+	for i := 0; i < 3; i++ {
+		logger.Warn("disk almost full")
+	}
+	// no further entry and no Sync: the window end writes the summary
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		entries := readJSONLinesNoSync(t, path)
+		if len(entries) == 2 {
+			if entries[1]["msg"] != "message repeated 2 times: disk almost full" {
+				t.Fatalf("summary = %v", entries[1])
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("entries = %v, want the entry and its summary", entries)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	logger.Warn("disk almost full")
+	if entries := readJSONLines(t, path); len(entries) != 3 {
+		t.Errorf("entries after the window = %d, want 3", len(entries))
+	}
+}
@@ -0,0 +1,138 @@
+//go:build go1.21
+
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// SlogHandler returns an slog.Handler that writes to the global logger, so code using
+// log/slog ends up in the same outputs. Registered context keys are added as fields,
+// inside the current group if WithGroup was used.
+//
+//	slog.SetDefault(slog.New(logger.SlogHandler()))
+func SlogHandler() slog.Handler {
+	return &slogHandler{zl: customLogger()}
+}
+
+type slogHandler struct {
+	zl *zap.Logger
+}
+
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.zl.Core().Enabled(slogLevel(level))
+}
+
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	ent := zapcore.Entry{
+		Level:   slogLevel(r.Level),
+		Time:    r.Time,
+		Message: r.Message,
+	}
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		ent.Caller = zapcore.NewEntryCaller(frame.PC, frame.File, frame.Line, true)
+	}
+	ce := h.zl.Core().Check(ent, nil)
+	if ce == nil {
+		return nil
+	}
+
+	var fields []Field
+	if ctx != nil {
+		fields = contextValues(ctx)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		if f, ok := slogField(a); ok {
+			fields = append(fields, f)
+		}
+		return true
+	})
+	ce.Write(fields...)
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make([]Field, 0, len(attrs))
+	for _, a := range attrs {
+		if f, ok := slogField(a); ok {
+			fields = append(fields, f)
+		}
+	}
+	return &slogHandler{zl: h.zl.With(fields...)}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{zl: h.zl.With(zap.Namespace(name))}
+}
+
+func slogLevel(l slog.Level) zapcore.Level {
+	switch {
+	case l < slog.LevelInfo:
+		return zapcore.DebugLevel
+	case l < slog.LevelWarn:
+		return zapcore.InfoLevel
+	case l < slog.LevelError:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.ErrorLevel
+	}
+}
+
+// slogField converts an attribute; empty attributes are dropped as slog requires.
+func slogField(a slog.Attr) (Field, bool) {
+	v := a.Value.Resolve()
+	if a.Key == "" && v.Kind() != slog.KindGroup {
+		return Field{}, false
+	}
+	switch v.Kind() {
+	case slog.KindString:
+		return zap.String(a.Key, v.String()), true
+	case slog.KindInt64:
+		return zap.Int64(a.Key, v.Int64()), true
+	case slog.KindUint64:
+		return zap.Uint64(a.Key, v.Uint64()), true
+	case slog.KindFloat64:
+		return zap.Float64(a.Key, v.Float64()), true
+	case slog.KindBool:
+		return zap.Bool(a.Key, v.Bool()), true
+	case slog.KindDuration:
+		return zap.Duration(a.Key, v.Duration()), true
+	case slog.KindTime:
+		return zap.Time(a.Key, v.Time()), true
+	case slog.KindGroup:
+		attrs := v.Group()
+		if len(attrs) == 0 {
+			return Field{}, false
+		}
+		if a.Key == "" {
+			// an inline group: slog expects its attributes at the current level
+			return zap.Inline(slogGroup(attrs)), true
+		}
+		return zap.Object(a.Key, slogGroup(attrs)), true
+	default:
+		if err, ok := v.Any().(error); ok {
+			return zap.NamedError(a.Key, err), true
+		}
+		return zap.Any(a.Key, v.Any()), true
+	}
+}
+
+type slogGroup []slog.Attr
+
+func (g slogGroup) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, a := range g {
+		if f, ok := slogField(a); ok {
+			f.AddTo(enc)
+		}
+	}
+	return nil
+}
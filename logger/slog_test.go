@@ -0,0 +1,68 @@
+//go:build go1.21
+
+package logger_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/liuxiaodao666/go-util/logger"
+)
+
+func TestSlogHandler(t *testing.T) {
+	path := initJSONLogger(t)
+	if err := logger.SetLevel("info"); err != nil {
+		t.Fatal(err)
+	}
+
+	l := slog.New(logger.SlogHandler()).With("service", "api")
+	l.Debug("hidden")
+	ctx := context.WithValue(context.Background(), logger.RequestIDKey, "req-1")
+	l.WithGroup("http").InfoContext(ctx, "request", "status", 200, slog.Group("client", "ip", "10.0.0.1"))
+	l.Error("failed", "err", errors.New("boom"))
+
+	entries := readJSONLines(t, path)
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	e := entries[0]
+	httpGroup, _ := e["http"].(map[string]interface{})
+	if e["service"] != "api" || httpGroup["request_id"] != "req-1" || httpGroup["status"] != float64(200) {
+		t.Errorf("entry 0 = %v", e)
+	}
+	if client, _ := httpGroup["client"].(map[string]interface{}); client["ip"] != "10.0.0.1" {
+		t.Errorf("entry 0 client = %v", httpGroup["client"])
+	}
+	if caller, _ := e["caller"].(string); !strings.HasPrefix(caller, "logger/slog_test.go") {
+		t.Errorf("caller = %q, want the test file", caller)
+	}
+	if entries[1]["level"] != "ERROR" || entries[1]["err"] != "boom" {
+		t.Errorf("entry 1 = %v", entries[1])
+	}
+}
+
+func TestSlogRedaction(t *testing.T) {
+	path := initJSONLoggerWith(t, func(cfg *logger.Config) {
+		cfg.Redact = logger.RedactConfig{Fields: []string{"password"}, Patterns: []string{"email"}}
+	})
+
+	l := slog.New(logger.SlogHandler())
+	l.Info("signup",
+		slog.Any("form", map[string]interface{}{"email": "alice@example.com", "password": "hunter2"}),
+		slog.Group("user", "password", "pw-group", "name", "alice"),
+		slog.Any("tags", []string{"bob@example.org"}),
+	)
+
+	raw := readFile(t, path)
+	for _, secret := range []string{"alice@example.com", "hunter2", "pw-group", "bob@example.org"} {
+		if strings.Contains(raw, secret) {
+			t.Errorf("log file contains %q: %s", secret, raw)
+		}
+	}
+	if !strings.Contains(raw, `"name":"alice"`) {
+		t.Errorf("group lost its other attrs: %s", raw)
+	}
+}
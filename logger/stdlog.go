@@ -0,0 +1,35 @@
+package logger
+
+import (
+	"log"
+
+	"go.uber.org/zap"
+)
+
+// stdLogger returns the global logger without the caller skip of the package-level
+// helpers, because zap adds its own skip for the standard log frames.
+func stdLogger() *zap.Logger {
+	return customLogger().WithOptions(zap.AddCallerSkip(-1))
+}
+
+// RedirectStdLog sends everything written through the standard log package to the
+// global logger at level. It returns a function that restores the previous output.
+// The standard logger keeps writing to the logger that was current at redirect time,
+// so call it again after Init.
+func RedirectStdLog(level string) (func(), error) {
+	l, err := parseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	return zap.RedirectStdLogAt(stdLogger(), l)
+}
+
+// NewStdLog returns a *log.Logger that writes to the global logger at level,
+// for libraries that take one, e.g. http.Server.ErrorLog.
+func NewStdLog(level string) (*log.Logger, error) {
+	l, err := parseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	return zap.NewStdLogAt(stdLogger(), l)
+}
@@ -0,0 +1,40 @@
+package logger_test
+
+import (
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/liuxiaodao666/go-util/logger"
+)
+
+func TestRedirectStdLog(t *testing.T) {
+	path := initJSONLogger(t)
+
+	restore, err := logger.RedirectStdLog("warn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Printf("third-party says %d", 42)
+	restore()
+
+	std, err := logger.NewStdLog("error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	std.Print("http: TLS handshake error")
+
+	entries := readJSONLines(t, path)
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if entries[0]["level"] != "WARN" || entries[0]["msg"] != "third-party says 42" {
+		t.Errorf("entry 0 = %v", entries[0])
+	}
+	if caller, _ := entries[0]["caller"].(string); !strings.HasPrefix(caller, "logger/stdlog_test.go") {
+		t.Errorf("caller = %q, want the test file", caller)
+	}
+	if entries[1]["level"] != "ERROR" {
+		t.Errorf("entry 1 = %v", entries[1])
+	}
+}
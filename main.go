@@ -1,13 +1,25 @@
 package main
 
 import (
+	"context"
 	"net/http"
+	"time"
 
 	"github.com/liuxiaodao666/go-util/logger"
-	_ "github.com/liuxiaodao666/go-util/pprof"
+	"github.com/liuxiaodao666/go-util/pprof"
 )
 
 func main() {
+	profiling := pprof.NewServer(pprof.DefaultConfig())
+	if err := profiling.Start(); err != nil {
+		logger.Errorf("start pprof server: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = profiling.Shutdown(ctx)
+	}()
+
 	logger.Errorf("mock %s", "error")
 	http.Get("https://www.baidu.com")
 
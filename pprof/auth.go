@@ -0,0 +1,139 @@
+package pprof
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/liuxiaodao666/go-util/logger"
+)
+
+// protect wraps h with the allow-list and authentication of s.cfg and logs each attempt
+// to the "pprof" module logger.
+// An invalid allow-list denies every request; Start reports the error.
+func (s *Server) protect(h http.Handler) http.Handler {
+	nets, cidrErr := parseCIDRs(s.cfg.AllowCIDRs)
+	unix := strings.HasPrefix(s.cfg.Addr, "unix:")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accessLog := logger.Module("pprof")
+		remote := remoteIP(r)
+		deny := func(code int, reason string) {
+			accessLog.Warnw("pprof access denied", "remote", r.RemoteAddr, "method", r.Method,
+				"path", r.URL.Path, "reason", reason)
+			http.Error(w, http.StatusText(code), code)
+		}
+
+		switch {
+		case cidrErr != nil:
+			deny(http.StatusForbidden, "invalid allow-list")
+			return
+		case len(nets) > 0 && !allowed(nets, remote, unix):
+			deny(http.StatusForbidden, "address not allowed")
+			return
+		}
+		user, ok := s.authenticate(r)
+		if !ok {
+			if s.cfg.BasicAuthUser != "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="pprof"`)
+			}
+			deny(http.StatusUnauthorized, "authentication failed")
+			return
+		}
+
+		accessLog.Infow("pprof access", "remote", r.RemoteAddr, "method", r.Method,
+			"path", r.URL.Path, "query", r.URL.RawQuery, "user", user)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// authenticate checks the credentials and returns the user name, "bearer" for a
+// token, or "" when no authentication is configured.
+func (s *Server) authenticate(r *http.Request) (string, bool) {
+	basic, bearer := s.cfg.BasicAuthUser != "", s.cfg.BearerToken != ""
+	if !basic && !bearer {
+		return "", true
+	}
+	if bearer {
+		auth := r.Header.Get("Authorization")
+		if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") && equal(auth[7:], s.cfg.BearerToken) {
+			return "bearer", true
+		}
+	}
+	if basic {
+		if u, p, ok := r.BasicAuth(); ok {
+			// compare both so the timing does not tell which one was wrong
+			userOK := equal(u, s.cfg.BasicAuthUser)
+			passOK := equal(p, s.cfg.BasicAuthPassword)
+			if userOK && passOK {
+				return u, true
+			}
+		}
+	}
+	return "", false
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range cidrs {
+		c := entry
+		if !strings.Contains(c, "/") {
+			if ip := net.ParseIP(c); ip != nil && ip.To4() != nil {
+				c += "/32"
+			} else {
+				c += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("pprof: invalid allow-list entry %q", entry)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// remoteIP returns the connection's IP, or nil for a unix socket peer.
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// allowed reports whether ip is in nets. A peer without an IP is allowed only when
+// the server listens on a unix socket, where peers are local; on TCP it means
+// RemoteAddr was not an address, e.g. rewritten by a middleware.
+func allowed(nets []*net.IPNet, ip net.IP, unix bool) bool {
+	if ip == nil {
+		return unix
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// isLocalAddr reports whether addr only accepts local connections.
+func isLocalAddr(addr string) bool {
+	if strings.HasPrefix(addr, "unix:") {
+		return true
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,131 @@
+package pprof
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/liuxiaodao666/go-util/logger"
+	"github.com/liuxiaodao666/go-util/logger/logtest"
+)
+
+func serveAs(h http.Handler, remote string, setup func(r *http.Request)) int {
+	r := httptest.NewRequest(http.MethodGet, "/debug/pprof/cmdline", nil)
+	r.RemoteAddr = remote
+	if setup != nil {
+		setup(r)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestAllowCIDRs(t *testing.T) {
+	logs := logtest.Observe(t)
+	h := NewServer(Config{AllowCIDRs: []string{"10.0.0.0/8", "192.0.2.7", "::1"}}).Handler()
+
+	for remote, want := range map[string]int{
+		"10.1.2.3:5000":  200,
+		"192.0.2.7:5000": 200,
+		"192.0.2.8:5000": 403,
+		"[::1]:5000":     200,
+		"@":              403, // no IP, but the server is not on a unix socket
+	} {
+		if code := serveAs(h, remote, nil); code != want {
+			t.Errorf("%s = %d, want %d", remote, code, want)
+		}
+	}
+	logs.AssertLogged(t, "warn", "pprof access denied",
+		logger.String("remote", "192.0.2.8:5000"), logger.String("reason", "address not allowed"))
+	logs.AssertLogged(t, "info", "pprof access", logger.String("remote", "10.1.2.3:5000"))
+}
+
+func TestInvalidAllowList(t *testing.T) {
+	s := NewServer(Config{Addr: "127.0.0.1:0", AllowCIDRs: []string{"10.0.0.0/99"}})
+	err := s.Start()
+	if err == nil {
+		t.Fatal("Start accepted an invalid allow-list")
+	}
+	if !strings.Contains(err.Error(), `"10.0.0.0/99"`) {
+		t.Errorf("error %q does not name the entry", err)
+	}
+	if _, err := parseCIDRs([]string{"not-an-ip"}); err == nil || !strings.Contains(err.Error(), `"not-an-ip"`) {
+		t.Errorf("parseCIDRs error = %v, want the original entry", err)
+	}
+	if code := serveAs(s.Handler(), "127.0.0.1:1", nil); code != 403 {
+		t.Errorf("invalid allow-list served %d, want 403", code)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	logs := logtest.Observe(t)
+	h := NewServer(Config{BasicAuthUser: "ops", BasicAuthPassword: "pw", BearerToken: "tok"}).Handler()
+	const remote = "127.0.0.1:1"
+
+	if code := serveAs(h, remote, nil); code != 401 {
+		t.Errorf("no credentials = %d, want 401", code)
+	}
+	if code := serveAs(h, remote, func(r *http.Request) { r.SetBasicAuth("ops", "wrong") }); code != 401 {
+		t.Errorf("wrong password = %d, want 401", code)
+	}
+	if code := serveAs(h, remote, func(r *http.Request) { r.SetBasicAuth("ops", "pw") }); code != 200 {
+		t.Errorf("basic auth = %d, want 200", code)
+	}
+	if code := serveAs(h, remote, func(r *http.Request) { r.Header.Set("Authorization", "Bearer tok") }); code != 200 {
+		t.Errorf("bearer = %d, want 200", code)
+	}
+	if code := serveAs(h, remote, func(r *http.Request) { r.Header.Set("Authorization", "Bearer nope") }); code != 401 {
+		t.Errorf("wrong token = %d, want 401", code)
+	}
+
+	logs.AssertLogged(t, "info", "pprof access", logger.String("user", "ops"))
+	logs.AssertLogged(t, "info", "pprof access", logger.String("user", "bearer"))
+	if n := len(logs.Field("reason", "authentication failed")); n != 3 {
+		t.Errorf("denied entries = %d, want 3", n)
+	}
+}
+
+func TestLocalOnly(t *testing.T) {
+	for addr, ok := range map[string]bool{
+		"127.0.0.1:0": true,
+		"[::1]:0":     true,
+		"localhost:0": true,
+		":0":          false,
+		"0.0.0.0:0":   false,
+	} {
+		err := Config{Addr: addr, LocalOnly: true}.Validate()
+		if (err == nil) != ok {
+			t.Errorf("LocalOnly %q: err = %v", addr, err)
+		}
+	}
+	if err := (Config{BasicAuthUser: "ops"}).Validate(); err == nil {
+		t.Error("basic auth without password accepted")
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "pprof.sock")
+	// unix socket peers have no IP and pass the allow-list
+	s := startServer(t, Config{Addr: "unix:" + sock, LocalOnly: true, AllowCIDRs: []string{"10.0.0.0/8"}})
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+		},
+	}}
+	resp, err := client.Get("http://pprof/debug/pprof/cmdline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Errorf("unix socket = %d", resp.StatusCode)
+	}
+	if s.Addr() != sock {
+		t.Errorf("Addr = %q, want %q", s.Addr(), sock)
+	}
+}
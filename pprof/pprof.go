@@ -1,22 +1,225 @@
 package pprof
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"net/http/pprof"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/liuxiaodao666/go-util/logger"
 )
 
+// Profiles are the named runtime profiles served under the path prefix.
+var Profiles = []string{"heap", "goroutine", "allocs", "block", "mutex", "threadcreate"}
+
+// Config configures a profiling Server.
+type Config struct {
+	// Addr is the listen address. Default "127.0.0.1:6060", so the profiles are
+	// not exposed on every interface. "unix:/run/app/pprof.sock" listens on a unix socket.
+	Addr string
+	// LocalOnly refuses to start unless Addr is a loopback address or a unix socket.
+	LocalOnly bool
+	// PathPrefix is where the handlers are mounted. Default "/debug/pprof".
+	PathPrefix string
+	// ReadTimeout bounds reading a request. Default 10s.
+	ReadTimeout time.Duration
+	// WriteTimeout bounds writing a response; it must exceed the longest CPU
+	// profile or trace requested with ?seconds=. Default 90s.
+	WriteTimeout time.Duration
+	// AllowCIDRs restricts the clients by their connection address, e.g. ["10.0.0.0/8"].
+	// Empty allows every address. Forwarding headers are not trusted.
+	AllowCIDRs []string
+	// BasicAuthUser and BasicAuthPassword require HTTP basic auth.
+	BasicAuthUser     string
+	BasicAuthPassword string
+	// BearerToken requires "Authorization: Bearer <token>". With basic auth also set,
+	// either is accepted.
+	BearerToken string
+	// BlockProfileRate and MutexProfileFraction are applied by Start, see Rates.
+	// They can be changed at runtime through <PathPrefix>/rates.
+	BlockProfileRate     int
+	MutexProfileFraction int
+}
+
+// Validate checks the listen address and the allow-list.
+func (c Config) Validate() error {
+	if _, err := parseCIDRs(c.AllowCIDRs); err != nil {
+		return err
+	}
+	if (c.BasicAuthUser == "") != (c.BasicAuthPassword == "") {
+		return errors.New("pprof: basic auth needs both user and password")
+	}
+	if c.LocalOnly && !isLocalAddr(c.Addr) {
+		return fmt.Errorf("pprof: %q is not a loopback address", c.Addr)
+	}
+	return nil
+}
+
+// DefaultConfig returns the default Config.
+func DefaultConfig() Config {
+	return Config{
+		Addr:         "127.0.0.1:6060",
+		PathPrefix:   "/debug/pprof",
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 90 * time.Second,
+	}
+}
+
+// Server serves the pprof endpoints on its own listener.
+type Server struct {
+	cfg     Config
+	mux     *http.ServeMux
+	handler http.Handler
+	srv     *http.Server
+
+	mu   sync.Mutex
+	ln   net.Listener
+	done chan struct{}
+	// prevRates are the rates before Start, restored by Shutdown
+	prevRates Rates
+}
+
+// NewServer returns a Server for cfg; zero fields use the defaults.
+func NewServer(cfg Config) *Server {
+	def := DefaultConfig()
+	if cfg.Addr == "" {
+		cfg.Addr = def.Addr
+	}
+	cfg.PathPrefix = "/" + strings.Trim(cfg.PathPrefix, "/")
+	if cfg.PathPrefix == "/" {
+		cfg.PathPrefix = def.PathPrefix
+	}
+	if cfg.ReadTimeout <= 0 {
+		cfg.ReadTimeout = def.ReadTimeout
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = def.WriteTimeout
+	}
+
+	s := &Server{cfg: cfg, mux: http.NewServeMux()}
+	s.register()
+	s.handler = s.protect(s.mux)
+	s.srv = &http.Server{
+		Handler:      s.handler,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+	}
+	return s
+}
+
+func (s *Server) register() {
+	prefix := s.cfg.PathPrefix
+	s.mux.HandleFunc(prefix+"/", s.index)
+	s.mux.HandleFunc(prefix+"/cmdline", pprof.Cmdline)
+	s.mux.HandleFunc(prefix+"/profile", pprof.Profile)
+	s.mux.HandleFunc(prefix+"/symbol", pprof.Symbol)
+	s.mux.HandleFunc(prefix+"/trace", pprof.Trace)
+	s.mux.HandleFunc(prefix+"/rates", s.ratesHandler)
+	s.mux.HandleFunc(prefix+"/vars", varsHandler)
+	for _, name := range Profiles {
+		s.mux.Handle(prefix+"/"+name, pprof.Handler(name))
+	}
+}
+
+// index serves the profile list, and profiles not registered explicitly by name.
+// pprof.Index only understands the /debug/pprof/ prefix.
+func (s *Server) index(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, s.cfg.PathPrefix+"/")
+	if name != "" {
+		pprof.Handler(name).ServeHTTP(w, r)
+		return
+	}
+	pprof.Index(w, r)
+}
+
+// Handler returns the handler with every pprof endpoint behind the configured
+// allow-list and authentication, for mounting on another server.
+func (s *Server) Handler() http.Handler {
+	return s.handler
+}
+
+// Start listens on the configured address and serves in the background.
+// It returns the listen error, e.g. when the port is taken.
+func (s *Server) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ln != nil {
+		return errors.New("pprof: server already started")
+	}
+	if err := s.cfg.Validate(); err != nil {
+		return err
+	}
+	network, addr := "tcp", s.cfg.Addr
+	if strings.HasPrefix(addr, "unix:") {
+		network, addr = "unix", strings.TrimPrefix(addr, "unix:")
+	}
+	ln, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+	s.ln = ln
+	s.done = make(chan struct{})
+	s.prevRates = CurrentRates()
+	if s.cfg.BlockProfileRate > 0 || s.cfg.MutexProfileFraction > 0 {
+		SetRates(s.configRates())
+	}
+
+	go func() {
+		defer close(s.done)
+		if err := s.srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			logger.Errorf("pprof server on %s stopped: %v", ln.Addr(), err)
+		}
+	}()
+	logger.Infof("pprof server listening on %s %s%s/", network, ln.Addr(), s.cfg.PathPrefix)
+	return nil
+}
+
+// Addr returns the address the server listens on, useful with port 0,
+// or the configured address before Start.
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ln != nil {
+		return s.ln.Addr().String()
+	}
+	return s.cfg.Addr
+}
+
+// Shutdown stops accepting connections and waits for the running requests,
+// such as a CPU profile, until ctx is done. The profiling rates from before Start
+// are restored and a pending automatic reset is cancelled.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	done, prev := s.done, s.prevRates
+	s.mu.Unlock()
+	if done == nil {
+		return nil
+	}
+	SetRates(prev)
+	err := s.srv.Shutdown(ctx)
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	return err
+}
+
+// InitPprof serves the profiles on pprofPort (default 6060) of every interface and blocks.
+//
+// Deprecated: use NewServer, which binds to localhost by default and can be shut down.
 func InitPprof(pprofPort string) {
 	if pprofPort == "" {
 		pprofPort = "6060"
 	}
-	mux := http.NewServeMux()
-
-	// Register pprof handlers
-	mux.HandleFunc("/debug/pprof/", pprof.Index)
-	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
-	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
-	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
-	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
-
-	http.ListenAndServe(":"+pprofPort, mux)
+	s := NewServer(Config{Addr: ":" + pprofPort})
+	if err := s.Start(); err != nil {
+		logger.Errorf("pprof server: %v", err)
+		return
+	}
+	<-s.done
 }
@@ -0,0 +1,131 @@
+package pprof
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"reflect"
+	"runtime/pprof"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startServer(t *testing.T, cfg Config) *Server {
+	t.Helper()
+	if cfg.Addr == "" {
+		cfg.Addr = "127.0.0.1:0"
+	}
+	s := NewServer(cfg)
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		s.Shutdown(ctx)
+	})
+	return s
+}
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, string(b)
+}
+
+func TestServerProfiles(t *testing.T) {
+	s := startServer(t, Config{PathPrefix: "/internal/prof/"})
+	base := "http://" + s.Addr() + "/internal/prof/"
+
+	if code, body := get(t, base); code != 200 || !strings.Contains(body, "goroutine") {
+		t.Fatalf("index = %d %q", code, body)
+	}
+	for _, name := range Profiles {
+		if code, _ := get(t, base+name+"?debug=1"); code != 200 {
+			t.Errorf("%s = %d", name, code)
+		}
+	}
+	if code, body := get(t, base+"goroutine?debug=1"); code != 200 || !strings.Contains(body, "goroutine profile") {
+		t.Errorf("goroutine = %d %q", code, body)
+	}
+	if code, _ := get(t, base+"cmdline"); code != 200 {
+		t.Errorf("cmdline = %d", code)
+	}
+	if code, _ := get(t, base+"nosuchprofile"); code != http.StatusNotFound {
+		t.Errorf("unknown profile = %d, want 404", code)
+	}
+	if code, _ := get(t, "http://"+s.Addr()+"/debug/pprof/"); code != http.StatusNotFound {
+		t.Errorf("default prefix = %d, want 404", code)
+	}
+}
+
+func TestServerStartError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	s := NewServer(Config{Addr: ln.Addr().String()})
+	if err := s.Start(); err == nil {
+		t.Fatal("Start on a taken port succeeded")
+	}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown of a server that never started: %v", err)
+	}
+}
+
+func TestServerShutdown(t *testing.T) {
+	s := startServer(t, Config{})
+	if err := s.Start(); err == nil {
+		t.Error("second Start succeeded")
+	}
+
+	// a running CPU profile delays shutdown until ctx expires
+	reqCtx, cancelReq := context.WithCancel(context.Background())
+	defer waitCPUProfile(t)
+	defer cancelReq()
+	req, _ := http.NewRequestWithContext(reqCtx, http.MethodGet, "http://"+s.Addr()+"/debug/pprof/profile?seconds=5", nil)
+	go http.DefaultClient.Do(req)
+	time.Sleep(100 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Shutdown = %v, want deadline exceeded", err)
+	}
+	if _, err := http.Get("http://" + s.Addr() + "/debug/pprof/"); err == nil {
+		t.Error("server still accepting connections")
+	}
+}
+
+// waitCPUProfile waits until no CPU profile is running. The profiler is process
+// wide: tests using it run one after the other, and each must not leave a profile
+// behind for the next one.
+func waitCPUProfile(t *testing.T) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for pprof.StartCPUProfile(ioutil.Discard) != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("CPU profile still running")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	pprof.StopCPUProfile()
+}
+
+func TestDefaults(t *testing.T) {
+	s := NewServer(Config{})
+	if !reflect.DeepEqual(s.cfg, DefaultConfig()) {
+		t.Errorf("config = %+v", s.cfg)
+	}
+	if !strings.HasPrefix(s.Addr(), "127.0.0.1:") {
+		t.Errorf("default addr %q is not loopback", s.Addr())
+	}
+}
@@ -0,0 +1,142 @@
+package pprof
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/liuxiaodao666/go-util/logger"
+)
+
+// Rates are the sampling rates of the block and mutex profiles, which stay empty at 0.
+type Rates struct {
+	// BlockProfileRate samples one blocking event per this many nanoseconds blocked;
+	// 1 records every event. See runtime.SetBlockProfileRate.
+	BlockProfileRate int `json:"block_profile_rate"`
+	// MutexProfileFraction reports one in this many mutex contention events.
+	// See runtime.SetMutexProfileFraction.
+	MutexProfileFraction int `json:"mutex_profile_fraction"`
+}
+
+// rates tracks the current values; the runtime has no getter for the block rate.
+var rates struct {
+	sync.Mutex
+	cur   Rates
+	timer *time.Timer
+	// gen counts the changes, so a reset timer that fires after a later change,
+	// too late to be stopped, leaves that change alone
+	gen uint64
+}
+
+// CurrentRates returns the rates set through this package.
+func CurrentRates() Rates {
+	rates.Lock()
+	defer rates.Unlock()
+	return rates.cur
+}
+
+// SetRates applies r to the runtime. Negative values are treated as 0 (off).
+func SetRates(r Rates) {
+	rates.Lock()
+	defer rates.Unlock()
+	setRates(r)
+}
+
+// setRates applies r; rates must be locked. A pending automatic reset is cancelled.
+func setRates(r Rates) {
+	if r.BlockProfileRate < 0 {
+		r.BlockProfileRate = 0
+	}
+	if r.MutexProfileFraction < 0 {
+		r.MutexProfileFraction = 0
+	}
+	if rates.timer != nil {
+		rates.timer.Stop()
+		rates.timer = nil
+	}
+	runtime.SetBlockProfileRate(r.BlockProfileRate)
+	runtime.SetMutexProfileFraction(r.MutexProfileFraction)
+	rates.cur = r
+	rates.gen++
+}
+
+// ratesRequest is the PUT body of the rates endpoint. Omitted rates are unchanged.
+type ratesRequest struct {
+	BlockProfileRate     *int   `json:"block_profile_rate"`
+	MutexProfileFraction *int   `json:"mutex_profile_fraction"`
+	Duration             string `json:"duration"`
+}
+
+// ratesHandler shows the rates on GET, changes them on PUT and restores the
+// configured values on DELETE. PUT takes {"block_profile_rate":1,"mutex_profile_fraction":5}
+// and an optional "duration":"30s" after which the configured values come back.
+func (s *Server) ratesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	log := logger.Module("pprof")
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req ratesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+			return
+		}
+		var d time.Duration
+		if req.Duration != "" {
+			var err error
+			if d, err = time.ParseDuration(req.Duration); err != nil || d <= 0 {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("invalid duration %q", req.Duration))
+				return
+			}
+		}
+
+		rates.Lock()
+		next := rates.cur
+		if req.BlockProfileRate != nil {
+			next.BlockProfileRate = *req.BlockProfileRate
+		}
+		if req.MutexProfileFraction != nil {
+			next.MutexProfileFraction = *req.MutexProfileFraction
+		}
+		setRates(next)
+		if d > 0 {
+			reset, gen := s.configRates(), rates.gen
+			rates.timer = time.AfterFunc(d, func() {
+				rates.Lock()
+				if rates.gen != gen {
+					rates.Unlock()
+					return
+				}
+				setRates(reset)
+				rates.Unlock()
+				logger.Module("pprof").Infow("profiling rates reset", "block_profile_rate", reset.BlockProfileRate,
+					"mutex_profile_fraction", reset.MutexProfileFraction)
+			})
+		}
+		rates.Unlock()
+		log.Infow("profiling rates changed via http", "remote", r.RemoteAddr, "block_profile_rate", next.BlockProfileRate,
+			"mutex_profile_fraction", next.MutexProfileFraction, "duration", req.Duration)
+	case http.MethodDelete:
+		reset := s.configRates()
+		SetRates(reset)
+		log.Infow("profiling rates reset via http", "remote", r.RemoteAddr, "block_profile_rate", reset.BlockProfileRate,
+			"mutex_profile_fraction", reset.MutexProfileFraction)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	_ = json.NewEncoder(w).Encode(CurrentRates())
+}
+
+func (s *Server) configRates() Rates {
+	return Rates{BlockProfileRate: s.cfg.BlockProfileRate, MutexProfileFraction: s.cfg.MutexProfileFraction}
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
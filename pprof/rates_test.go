@@ -0,0 +1,133 @@
+package pprof
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liuxiaodao666/go-util/logger"
+	"github.com/liuxiaodao666/go-util/logger/logtest"
+)
+
+func doRates(t *testing.T, h http.Handler, method, body string) (int, Rates) {
+	t.Helper()
+	r := httptest.NewRequest(method, "/debug/pprof/rates", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	var got Rates
+	if w.Code == 200 {
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w.Code, got
+}
+
+func TestRatesEndpoint(t *testing.T) {
+	logs := logtest.Observe(t)
+	defer SetRates(Rates{})
+	s := NewServer(Config{Addr: "127.0.0.1:0", MutexProfileFraction: 10})
+	h := s.Handler()
+
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Shutdown(context.Background())
+	if code, got := doRates(t, h, http.MethodGet, ""); code != 200 || got != (Rates{MutexProfileFraction: 10}) {
+		t.Fatalf("GET = %d %+v", code, got)
+	}
+	if runtime.SetMutexProfileFraction(-1) != 10 {
+		t.Error("configured mutex fraction not applied")
+	}
+
+	code, got := doRates(t, h, http.MethodPut, `{"block_profile_rate":1}`)
+	if code != 200 || got != (Rates{BlockProfileRate: 1, MutexProfileFraction: 10}) {
+		t.Fatalf("PUT = %d %+v", code, got)
+	}
+	logs.AssertLogged(t, "info", "profiling rates changed", logger.Int("block_profile_rate", 1))
+
+	if code, got := doRates(t, h, http.MethodDelete, ""); code != 200 || got != (Rates{MutexProfileFraction: 10}) {
+		t.Fatalf("DELETE = %d %+v", code, got)
+	}
+
+	for _, body := range []string{`{`, `{"duration":"soon"}`} {
+		if code, _ := doRates(t, h, http.MethodPut, body); code != http.StatusBadRequest {
+			t.Errorf("PUT %s = %d, want 400", body, code)
+		}
+	}
+	if code, _ := doRates(t, h, http.MethodPost, ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("POST = %d, want 405", code)
+	}
+}
+
+func TestRatesAutoReset(t *testing.T) {
+	logs := logtest.Observe(t)
+	defer SetRates(Rates{})
+	h := NewServer(Config{}).Handler()
+
+	code, got := doRates(t, h, http.MethodPut, `{"block_profile_rate":1,"mutex_profile_fraction":5,"duration":"30ms"}`)
+	if code != 200 || got != (Rates{BlockProfileRate: 1, MutexProfileFraction: 5}) {
+		t.Fatalf("PUT = %d %+v", code, got)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	// wait for the log, written after the reset, so the timer is done with the logger
+	for len(logs.Message("profiling rates reset")) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("rates not reset: %+v", CurrentRates())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := CurrentRates(); got != (Rates{}) {
+		t.Errorf("rates after reset = %+v", got)
+	}
+	if runtime.SetMutexProfileFraction(-1) != 0 {
+		t.Error("runtime mutex fraction not reset")
+	}
+}
+
+func TestRatesStaleReset(t *testing.T) {
+	logtest.Observe(t)
+	defer SetRates(Rates{})
+	h := NewServer(Config{}).Handler()
+
+	doRates(t, h, http.MethodPut, `{"block_profile_rate":1,"duration":"10ms"}`)
+	// the timer fires while a later change holds the lock, too late to be stopped
+	rates.Lock()
+	time.Sleep(50 * time.Millisecond)
+	setRates(Rates{BlockProfileRate: 7})
+	rates.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	if got := CurrentRates(); got != (Rates{BlockProfileRate: 7}) {
+		t.Errorf("rates = %+v, want the later change kept", got)
+	}
+}
+
+func TestShutdownRestoresRates(t *testing.T) {
+	logtest.Observe(t)
+	defer SetRates(Rates{})
+	SetRates(Rates{MutexProfileFraction: 3})
+
+	s := startServer(t, Config{BlockProfileRate: 1, MutexProfileFraction: 5})
+	if got := CurrentRates(); got != (Rates{BlockProfileRate: 1, MutexProfileFraction: 5}) {
+		t.Fatalf("rates after Start = %+v", got)
+	}
+	doRates(t, s.Handler(), http.MethodPut, `{"block_profile_rate":100,"duration":"1h"}`)
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := CurrentRates(); got != (Rates{MutexProfileFraction: 3}) {
+		t.Errorf("rates after Shutdown = %+v, want the rates before Start", got)
+	}
+	rates.Lock()
+	pending := rates.timer != nil
+	rates.Unlock()
+	if pending {
+		t.Error("automatic reset still pending after Shutdown")
+	}
+}
@@ -0,0 +1,272 @@
+package pprof
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/liuxiaodao666/go-util/logger"
+)
+
+// SnapshotConfig configures continuous profiling.
+type SnapshotConfig struct {
+	// Dir receives the profiles. Default "./log/pprof".
+	Dir string
+	// Interval is the time between captures. Default 5m.
+	Interval time.Duration
+	// CPUDuration is how long the CPU profile of each capture runs. Default 10s.
+	CPUDuration time.Duration
+	// Profiles are the captured profiles: "cpu" or a runtime profile name such as
+	// "heap", "goroutine", "allocs", "block", "mutex", "threadcreate".
+	// Default cpu, heap, goroutine and mutex. Block and mutex stay empty until their
+	// sampling rates are set, see SetRates.
+	Profiles []string
+	// MaxFiles is the number of files kept per profile and tag. Default 48.
+	MaxFiles int
+	// MaxAge removes files older than this. Zero keeps them until MaxFiles is reached.
+	MaxAge time.Duration
+}
+
+func (c *SnapshotConfig) setDefaults() {
+	if c.Dir == "" {
+		c.Dir = "./log/pprof"
+	}
+	if c.Interval <= 0 {
+		c.Interval = 5 * time.Minute
+	}
+	if c.CPUDuration <= 0 {
+		c.CPUDuration = 10 * time.Second
+	}
+	if len(c.Profiles) == 0 {
+		c.Profiles = []string{"cpu", "heap", "goroutine", "mutex"}
+	}
+	if c.MaxFiles <= 0 {
+		c.MaxFiles = 48
+	}
+}
+
+// Validate checks the profile names and that a CPU profile fits in the interval.
+func (c SnapshotConfig) Validate() error {
+	c.setDefaults()
+	for _, p := range c.Profiles {
+		if p != "cpu" && pprof.Lookup(p) == nil {
+			return fmt.Errorf("pprof: unknown profile %q", p)
+		}
+	}
+	if c.CPUDuration >= c.Interval {
+		return fmt.Errorf("pprof: cpu duration %v must be shorter than the interval %v", c.CPUDuration, c.Interval)
+	}
+	return nil
+}
+
+// Snapshotter writes profiles to disk periodically, so the state before an incident
+// can be examined afterwards.
+type Snapshotter struct {
+	cfg SnapshotConfig
+	now func() time.Time
+	// groups are the file name prefixes up to the timestamp, e.g. "heap-", that
+	// prune may remove; other files in Dir are left alone
+	groups map[string]bool
+
+	// capturing serializes captures; a CPU profile cannot run twice
+	capturing sync.Mutex
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewSnapshotter returns a Snapshotter for cfg; zero fields use the defaults.
+func NewSnapshotter(cfg SnapshotConfig) *Snapshotter {
+	cfg.setDefaults()
+	groups := make(map[string]bool, len(cfg.Profiles))
+	for _, p := range cfg.Profiles {
+		groups[p+"-"] = true
+	}
+	return &Snapshotter{cfg: cfg, now: time.Now, groups: groups}
+}
+
+// Start captures every Interval in a background goroutine until Stop.
+func (s *Snapshotter) Start() error {
+	if err := s.cfg.Validate(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.cfg.Dir, 0755); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		return errors.New("pprof: snapshotter already started")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel, s.done = cancel, make(chan struct{})
+	go s.run(ctx, s.done)
+	logger.Infof("pprof snapshots every %v to %s: %s", s.cfg.Interval, s.cfg.Dir, strings.Join(s.cfg.Profiles, ","))
+	return nil
+}
+
+// Stop ends the background captures, interrupting a running CPU profile.
+func (s *Snapshotter) Stop() {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel, s.done = nil, nil
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+func (s *Snapshotter) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := s.Capture(ctx); err != nil && ctx.Err() == nil {
+			logger.Warnf("pprof snapshot: %v", err)
+		}
+	}
+}
+
+// Capture writes one file per configured profile now and applies the retention limits.
+// It returns the written files; a failing profile does not stop the others.
+func (s *Snapshotter) Capture(ctx context.Context) ([]string, error) {
+	return s.capture(ctx, "", s.cfg.Profiles)
+}
+
+// capture writes profiles as <dir>/<profile>[-<tag>]-<time>[_<n>].pb.gz, where n
+// numbers the later captures within the same second.
+func (s *Snapshotter) capture(ctx context.Context, tag string, profiles []string) ([]string, error) {
+	s.capturing.Lock()
+	defer s.capturing.Unlock()
+
+	if err := os.MkdirAll(s.cfg.Dir, 0755); err != nil {
+		return nil, err
+	}
+	if tag != "" {
+		tag = "-" + tag
+	}
+	stamp := s.now().UTC().Format("20060102T150405Z")
+	// "_" sorts after ".", so the suffixed files stay newer for prune
+	for n, base := 1, stamp; ; n++ {
+		taken := false
+		for _, p := range profiles {
+			if _, err := os.Stat(filepath.Join(s.cfg.Dir, p+tag+"-"+stamp+".pb.gz")); err == nil {
+				taken = true
+				break
+			}
+		}
+		if !taken {
+			break
+		}
+		stamp = fmt.Sprintf("%s_%d", base, n)
+	}
+
+	var files, errs []string
+	for _, p := range profiles {
+		path := filepath.Join(s.cfg.Dir, p+tag+"-"+stamp+".pb.gz")
+		if err := s.writeProfile(ctx, p, path); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", p, err))
+			continue
+		}
+		files = append(files, path)
+	}
+	if err := s.prune(); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) > 0 {
+		return files, errors.New(strings.Join(errs, "; "))
+	}
+	return files, nil
+}
+
+func (s *Snapshotter) writeProfile(ctx context.Context, name, path string) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+
+	if name != "cpu" {
+		return pprof.Lookup(name).WriteTo(f, 0)
+	}
+	// fails when another CPU profile, e.g. /debug/pprof/profile, is running
+	if err := pprof.StartCPUProfile(f); err != nil {
+		return err
+	}
+	t := time.NewTimer(s.cfg.CPUDuration)
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+		t.Stop()
+	}
+	pprof.StopCPUProfile()
+	return nil
+}
+
+// prune keeps at most MaxFiles files per profile and removes those older than MaxAge.
+// Only the files of s.groups are considered.
+func (s *Snapshotter) prune() error {
+	matches, err := filepath.Glob(filepath.Join(s.cfg.Dir, "*.pb.gz"))
+	if err != nil {
+		return err
+	}
+	type file struct {
+		path string
+		mod  time.Time
+	}
+	byProfile := map[string][]file{}
+	for _, m := range matches {
+		info, err := os.Stat(m)
+		if err != nil {
+			continue
+		}
+		// group by the name before the timestamp: "heap", "heap-<tag>"
+		base := filepath.Base(m)
+		profile := base[:strings.LastIndexByte(base, '-')+1]
+		if !s.groups[profile] {
+			continue
+		}
+		byProfile[profile] = append(byProfile[profile], file{m, info.ModTime()})
+	}
+
+	var errs []string
+	cutoff := s.now().Add(-s.cfg.MaxAge)
+	for _, files := range byProfile {
+		// newest first
+		sort.Slice(files, func(i, j int) bool { return files[i].path > files[j].path })
+		for i, f := range files {
+			if i < s.cfg.MaxFiles && (s.cfg.MaxAge <= 0 || f.mod.After(cutoff)) {
+				continue
+			}
+			if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("pprof: prune snapshots: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
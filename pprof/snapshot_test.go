@@ -0,0 +1,139 @@
+package pprof
+
+import (
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func profileFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	m, err := filepath.Glob(filepath.Join(dir, "*.pb.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range m {
+		m[i] = filepath.Base(m[i])
+	}
+	sort.Strings(m)
+	return m
+}
+
+func TestSnapshotCapture(t *testing.T) {
+	dir := t.TempDir()
+	s := NewSnapshotter(SnapshotConfig{Dir: dir, CPUDuration: 20 * time.Millisecond})
+	s.now = func() time.Time { return time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC) }
+
+	files, err := s.Capture(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 4 {
+		t.Fatalf("files = %v", files)
+	}
+	want := []string{
+		"cpu-20240310T120000Z.pb.gz", "goroutine-20240310T120000Z.pb.gz",
+		"heap-20240310T120000Z.pb.gz", "mutex-20240310T120000Z.pb.gz",
+	}
+	if got := profileFiles(t, dir); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("files = %v, want %v", got, want)
+	}
+	for _, name := range want {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gzip.NewReader(f); err != nil {
+			t.Errorf("%s is not a gzipped profile: %v", name, err)
+		}
+		f.Close()
+	}
+}
+
+func TestSnapshotSameSecond(t *testing.T) {
+	dir := t.TempDir()
+	s := NewSnapshotter(SnapshotConfig{Dir: dir, Profiles: []string{"heap"}, MaxFiles: 2})
+	s.now = func() time.Time { return time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC) }
+
+	for i := 0; i < 3; i++ {
+		if _, err := s.Capture(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the first capture is the oldest and pruned
+	want := "heap-20240310T120000Z_1.pb.gz heap-20240310T120000Z_2.pb.gz"
+	if got := strings.Join(profileFiles(t, dir), " "); got != want {
+		t.Errorf("files = %s, want %s", got, want)
+	}
+}
+
+func TestSnapshotRetention(t *testing.T) {
+	dir := t.TempDir()
+	s := NewSnapshotter(SnapshotConfig{Dir: dir, Profiles: []string{"heap"}, MaxFiles: 2, MaxAge: 90 * time.Minute})
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+
+	for i := 0; i < 4; i++ {
+		if _, err := s.Capture(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(time.Minute)
+	}
+	got := profileFiles(t, dir)
+	if strings.Join(got, " ") != "heap-20240310T120200Z.pb.gz heap-20240310T120300Z.pb.gz" {
+		t.Fatalf("kept %v", got)
+	}
+
+	// age out the older one
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(filepath.Join(dir, got[0]), old, old)
+	s.now = time.Now
+	if err := s.prune(); err != nil {
+		t.Fatal(err)
+	}
+	if got := profileFiles(t, dir); len(got) != 1 || got[0] != "heap-20240310T120300Z.pb.gz" {
+		t.Errorf("after MaxAge kept %v", got)
+	}
+}
+
+func TestSnapshotterStartStop(t *testing.T) {
+	dir := t.TempDir()
+	s := NewSnapshotter(SnapshotConfig{
+		Dir:         dir,
+		Interval:    30 * time.Millisecond,
+		CPUDuration: 10 * time.Millisecond,
+		Profiles:    []string{"cpu", "goroutine"},
+	})
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err == nil {
+		t.Error("second Start succeeded")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(profileFiles(t, dir)) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("no snapshot written")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.Stop()
+	s.Stop()
+}
+
+func TestSnapshotValidate(t *testing.T) {
+	if err := (SnapshotConfig{Profiles: []string{"heap", "bogus"}}).Validate(); err == nil {
+		t.Error("unknown profile accepted")
+	}
+	if err := (SnapshotConfig{Interval: time.Second, CPUDuration: time.Second}).Validate(); err == nil {
+		t.Error("cpu duration as long as the interval accepted")
+	}
+	if err := (SnapshotConfig{}).Validate(); err != nil {
+		t.Errorf("defaults: %v", err)
+	}
+}
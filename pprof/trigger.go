@@ -0,0 +1,274 @@
+package pprof
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/liuxiaodao666/go-util/logger"
+)
+
+// Trigger reasons, also used in the captured file names.
+const (
+	ReasonHeap       = "heap"
+	ReasonGoroutines = "goroutines"
+	ReasonGCPause    = "gcpause"
+	ReasonCPU        = "cpu"
+)
+
+// triggerProfiles are the profiles captured for each reason.
+var triggerProfiles = map[string][]string{
+	ReasonHeap:       {"heap"},
+	ReasonGoroutines: {"goroutine"},
+	ReasonGCPause:    {"heap", "allocs"},
+	ReasonCPU:        {"cpu"},
+}
+
+// TriggerConfig configures threshold-triggered captures. Zero thresholds are disabled.
+type TriggerConfig struct {
+	// HeapInUse triggers a heap profile when the in-use heap exceeds this many bytes.
+	HeapInUse uint64
+	// Goroutines triggers a goroutine profile above this count.
+	Goroutines int
+	// GCPause triggers heap and allocs profiles when a GC pause exceeds it.
+	GCPause time.Duration
+	// CPUPercent triggers a CPU profile when the process CPU usage, read from
+	// /proc/self/stat, exceeds it; 100 is one core. Linux only.
+	CPUPercent float64
+	// CheckInterval is how often the values are read. Default 5s.
+	CheckInterval time.Duration
+	// Cooldown is the minimum time between two captures for the same reason. Default 10m.
+	Cooldown time.Duration
+	// MaxCaptures stops capturing after this many captures in total. Default 10.
+	// Like the cooldown, it only counts captures that wrote files.
+	MaxCaptures int
+	// Snapshot sets the directory, CPU profile duration and retention of the files.
+	// The directory defaults to "./log/pprof/triggered", apart from the periodic
+	// snapshots. Interval and Profiles are not used.
+	Snapshot SnapshotConfig
+}
+
+// triggerStats are the values compared with the thresholds.
+type triggerStats struct {
+	heapInUse  uint64
+	goroutines int
+	maxPause   time.Duration
+	cpuPercent float64
+	cpuOK      bool
+}
+
+// Trigger captures profiles when a resource crosses its threshold.
+type Trigger struct {
+	cfg  TriggerConfig
+	snap *Snapshotter
+	now  func() time.Time
+	read func() triggerStats
+
+	// state of read, only used from the check goroutine
+	lastNumGC uint32
+	lastCPU   float64
+	lastWall  time.Time
+
+	mu       sync.Mutex
+	last     map[string]time.Time
+	captures int
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// NewTrigger returns a Trigger for cfg; zero fields use the defaults.
+func NewTrigger(cfg TriggerConfig) *Trigger {
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = 5 * time.Second
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = 10 * time.Minute
+	}
+	if cfg.MaxCaptures <= 0 {
+		cfg.MaxCaptures = 10
+	}
+	if cfg.Snapshot.Dir == "" {
+		cfg.Snapshot.Dir = "./log/pprof/triggered"
+	}
+	t := &Trigger{cfg: cfg, snap: NewSnapshotter(cfg.Snapshot), now: time.Now, last: map[string]time.Time{}}
+	// prune only the triggered files, even in a directory shared with periodic snapshots
+	t.snap.groups = map[string]bool{}
+	for reason, profiles := range triggerProfiles {
+		for _, p := range profiles {
+			t.snap.groups[p+"-"+reason+"-"] = true
+		}
+	}
+	t.read = t.readStats
+	return t
+}
+
+// Start checks the thresholds every CheckInterval in a background goroutine until Stop.
+func (t *Trigger) Start() error {
+	c := t.cfg
+	if c.HeapInUse == 0 && c.Goroutines == 0 && c.GCPause == 0 && c.CPUPercent == 0 {
+		return errors.New("pprof: no trigger threshold set")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cancel != nil {
+		return errors.New("pprof: trigger already started")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.cancel, t.done = cancel, make(chan struct{})
+	go t.run(ctx, t.done)
+	return nil
+}
+
+// Stop ends the checks, interrupting a running CPU profile.
+func (t *Trigger) Stop() {
+	t.mu.Lock()
+	cancel, done := t.cancel, t.done
+	t.cancel, t.done = nil, nil
+	t.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// Captures returns the number of captures made so far.
+func (t *Trigger) Captures() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.captures
+}
+
+func (t *Trigger) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	t.read() // prime the GC and CPU deltas
+	ticker := time.NewTicker(t.cfg.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		t.check(ctx)
+	}
+}
+
+// check reads the values once and captures for every threshold crossed.
+func (t *Trigger) check(ctx context.Context) {
+	st := t.read()
+	c := t.cfg
+	type crossing struct {
+		reason           string
+		value, threshold string
+	}
+	var crossed []crossing
+	if c.HeapInUse > 0 && st.heapInUse > c.HeapInUse {
+		crossed = append(crossed, crossing{ReasonHeap, strconv.FormatUint(st.heapInUse, 10), strconv.FormatUint(c.HeapInUse, 10)})
+	}
+	if c.Goroutines > 0 && st.goroutines > c.Goroutines {
+		crossed = append(crossed, crossing{ReasonGoroutines, strconv.Itoa(st.goroutines), strconv.Itoa(c.Goroutines)})
+	}
+	if c.GCPause > 0 && st.maxPause > c.GCPause {
+		crossed = append(crossed, crossing{ReasonGCPause, st.maxPause.String(), c.GCPause.String()})
+	}
+	if c.CPUPercent > 0 && st.cpuOK && st.cpuPercent > c.CPUPercent {
+		crossed = append(crossed, crossing{ReasonCPU, fmt.Sprintf("%.1f", st.cpuPercent), fmt.Sprintf("%.1f", c.CPUPercent)})
+	}
+
+	log := logger.Module("pprof")
+	for _, x := range crossed {
+		if !t.allow(x.reason) {
+			continue
+		}
+		files, err := t.snap.capture(ctx, x.reason, triggerProfiles[x.reason])
+		if err != nil {
+			log.Warnw("triggered profile capture failed", "reason", x.reason, "error", err.Error())
+		}
+		if len(files) > 0 {
+			// a failed capture, e.g. while another CPU profile runs, is retried next check
+			t.charge(x.reason)
+			log.Warnw("triggered profile captured", "reason", x.reason, "value", x.value,
+				"threshold", x.threshold, "files", files)
+		}
+	}
+}
+
+// allow reports whether a capture for reason is allowed now.
+func (t *Trigger) allow(reason string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.captures >= t.cfg.MaxCaptures {
+		return false
+	}
+	last, ok := t.last[reason]
+	return !ok || t.now().Sub(last) >= t.cfg.Cooldown
+}
+
+// charge counts a capture for reason and starts its cooldown.
+func (t *Trigger) charge(reason string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.last[reason] = t.now()
+	t.captures++
+}
+
+// readStats reads the runtime values and the CPU usage since the previous call.
+func (t *Trigger) readStats() triggerStats {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	st := triggerStats{heapInUse: ms.HeapInuse, goroutines: runtime.NumGoroutine()}
+
+	// the pauses of the GCs since the last read, at most the 256 kept by the runtime
+	n := ms.NumGC - t.lastNumGC
+	if n > 256 {
+		n = 256
+	}
+	for i := uint32(0); i < n; i++ {
+		p := time.Duration(ms.PauseNs[(ms.NumGC-i+255)%256])
+		if p > st.maxPause {
+			st.maxPause = p
+		}
+	}
+	t.lastNumGC = ms.NumGC
+
+	if cpu, err := processCPUSeconds(); err == nil {
+		wall := t.now()
+		if !t.lastWall.IsZero() {
+			if elapsed := wall.Sub(t.lastWall).Seconds(); elapsed > 0 {
+				st.cpuPercent, st.cpuOK = (cpu-t.lastCPU)/elapsed*100, true
+			}
+		}
+		t.lastCPU, t.lastWall = cpu, wall
+	}
+	return st
+}
+
+// clockTicks is USER_HZ, 100 on every common Linux platform.
+const clockTicks = 100
+
+// processCPUSeconds returns the user plus system CPU time of the process from /proc/self/stat.
+func processCPUSeconds() (float64, error) {
+	b, err := ioutil.ReadFile("/proc/self/stat")
+	if err != nil {
+		return 0, err
+	}
+	// the command name in parentheses may contain spaces
+	s := string(b)
+	fields := strings.Fields(s[strings.LastIndexByte(s, ')')+1:])
+	// utime and stime are fields 14 and 15 of the line, the 12th and 13th after the name
+	if len(fields) < 13 {
+		return 0, errors.New("pprof: unexpected /proc/self/stat format")
+	}
+	utime, err1 := strconv.ParseFloat(fields[11], 64)
+	stime, err2 := strconv.ParseFloat(fields[12], 64)
+	if err1 != nil || err2 != nil {
+		return 0, errors.New("pprof: unexpected /proc/self/stat format")
+	}
+	return (utime + stime) / clockTicks, nil
+}
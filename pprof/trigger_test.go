@@ -0,0 +1,176 @@
+package pprof
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liuxiaodao666/go-util/logger"
+	"github.com/liuxiaodao666/go-util/logger/logtest"
+)
+
+func newTestTrigger(t *testing.T, cfg TriggerConfig, st *triggerStats) (*Trigger, *time.Time) {
+	t.Helper()
+	cfg.Snapshot.Dir = t.TempDir()
+	cfg.Snapshot.CPUDuration = 10 * time.Millisecond
+	tr := NewTrigger(cfg)
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	tr.now = func() time.Time { return now }
+	tr.snap.now = tr.now
+	tr.read = func() triggerStats { return *st }
+	return tr, &now
+}
+
+func TestTriggerCapture(t *testing.T) {
+	logs := logtest.Observe(t)
+	st := &triggerStats{heapInUse: 100 << 20, goroutines: 50}
+	tr, _ := newTestTrigger(t, TriggerConfig{HeapInUse: 64 << 20, Goroutines: 100}, st)
+
+	tr.check(context.Background())
+	if tr.Captures() != 1 {
+		t.Fatalf("captures = %d, want 1", tr.Captures())
+	}
+	file := filepath.Join(tr.cfg.Snapshot.Dir, "heap-heap-20240310T120000Z.pb.gz")
+	if got := profileFiles(t, tr.cfg.Snapshot.Dir); len(got) != 1 || got[0] != filepath.Base(file) {
+		t.Fatalf("files = %v", got)
+	}
+	logs.AssertLogged(t, "warn", "triggered profile captured",
+		logger.String("reason", "heap"), logger.String("value", "104857600"),
+		logger.Strings("files", []string{file}))
+}
+
+func TestTriggerCooldownAndMax(t *testing.T) {
+	logtest.Observe(t)
+	st := &triggerStats{goroutines: 500, maxPause: 50 * time.Millisecond, cpuPercent: 300, cpuOK: true}
+	tr, now := newTestTrigger(t, TriggerConfig{
+		Goroutines:  100,
+		GCPause:     10 * time.Millisecond,
+		CPUPercent:  200,
+		Cooldown:    time.Minute,
+		MaxCaptures: 4,
+	}, st)
+
+	tr.check(context.Background())
+	if tr.Captures() != 3 {
+		t.Fatalf("captures = %d, want 3", tr.Captures())
+	}
+	want := []string{
+		"allocs-gcpause-20240310T120000Z.pb.gz", "cpu-cpu-20240310T120000Z.pb.gz",
+		"goroutine-goroutines-20240310T120000Z.pb.gz", "heap-gcpause-20240310T120000Z.pb.gz",
+	}
+	if got := profileFiles(t, tr.cfg.Snapshot.Dir); len(got) != 4 || got[0] != want[0] || got[3] != want[3] {
+		t.Fatalf("files = %v, want %v", got, want)
+	}
+
+	// still in the cooldown
+	*now = now.Add(30 * time.Second)
+	tr.check(context.Background())
+	if tr.Captures() != 3 {
+		t.Fatalf("captures in cooldown = %d, want 3", tr.Captures())
+	}
+
+	// after the cooldown only one more capture fits under MaxCaptures
+	*now = now.Add(time.Minute)
+	tr.check(context.Background())
+	if tr.Captures() != 4 {
+		t.Fatalf("captures = %d, want 4", tr.Captures())
+	}
+
+	// below the thresholds nothing happens
+	*st = triggerStats{}
+	*now = now.Add(time.Hour)
+	tr.check(context.Background())
+	if tr.Captures() != 4 {
+		t.Errorf("captures = %d, want 4", tr.Captures())
+	}
+}
+
+func TestTriggerFailedCaptureNotCharged(t *testing.T) {
+	logtest.Observe(t)
+	st := &triggerStats{cpuPercent: 300, cpuOK: true}
+	tr, _ := newTestTrigger(t, TriggerConfig{CPUPercent: 200, Cooldown: time.Hour, MaxCaptures: 1}, st)
+
+	// another CPU profile is running: the capture fails
+	if err := pprof.StartCPUProfile(ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	tr.check(context.Background())
+	pprof.StopCPUProfile()
+	if tr.Captures() != 0 {
+		t.Fatalf("captures = %d after a failed capture, want 0", tr.Captures())
+	}
+
+	// neither the cooldown nor MaxCaptures were charged
+	tr.check(context.Background())
+	if tr.Captures() != 1 {
+		t.Errorf("captures = %d, want 1", tr.Captures())
+	}
+}
+
+func TestTriggerSharedDir(t *testing.T) {
+	logtest.Observe(t)
+	st := &triggerStats{goroutines: 500}
+	tr, now := newTestTrigger(t, TriggerConfig{Goroutines: 100}, st)
+	dir := tr.cfg.Snapshot.Dir
+	tr.check(context.Background())
+
+	// periodic snapshots in the same directory keep only their own files
+	snap := NewSnapshotter(SnapshotConfig{Dir: dir, Profiles: []string{"goroutine"}, MaxFiles: 1})
+	for i := 0; i < 2; i++ {
+		*now = now.Add(time.Minute)
+		snap.now = tr.now
+		if _, err := snap.Capture(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "goroutine-20240310T120200Z.pb.gz goroutine-goroutines-20240310T120000Z.pb.gz"
+	if got := strings.Join(profileFiles(t, dir), " "); got != want {
+		t.Errorf("files = %s, want %s", got, want)
+	}
+}
+
+func TestTriggerDefaultDir(t *testing.T) {
+	if dir := NewTrigger(TriggerConfig{}).cfg.Snapshot.Dir; dir == NewSnapshotter(SnapshotConfig{}).cfg.Dir {
+		t.Errorf("triggered captures share the snapshot directory %s", dir)
+	}
+}
+
+func TestTriggerReadStats(t *testing.T) {
+	tr := NewTrigger(TriggerConfig{})
+	tr.readStats()
+	runtime.GC()
+	deadline := time.Now().Add(50 * time.Millisecond)
+	for time.Now().Before(deadline) {
+	}
+	st := tr.readStats()
+	if st.heapInUse == 0 || st.goroutines == 0 || st.maxPause <= 0 {
+		t.Errorf("stats = %+v", st)
+	}
+	if runtime.GOOS == "linux" && (!st.cpuOK || st.cpuPercent <= 0) {
+		t.Errorf("cpu = %v, %v", st.cpuPercent, st.cpuOK)
+	}
+}
+
+func TestTriggerStart(t *testing.T) {
+	if err := NewTrigger(TriggerConfig{}).Start(); err == nil {
+		t.Error("Start without thresholds succeeded")
+	}
+	tr := NewTrigger(TriggerConfig{Goroutines: 1, CheckInterval: 10 * time.Millisecond,
+		Snapshot: SnapshotConfig{Dir: t.TempDir()}})
+	if err := tr.Start(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for tr.Captures() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no capture")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	tr.Stop()
+}
@@ -0,0 +1,171 @@
+package pprof
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+// startTime is when the process, more precisely this package, was initialized.
+var startTime = time.Now()
+
+// vars holds the values published by other packages.
+var vars struct {
+	sync.RWMutex
+	m map[string]*publishedVar
+}
+
+type publishedVar struct {
+	fn func() interface{}
+}
+
+// Publish adds fn to <PathPrefix>/vars: its result is encoded as JSON under
+// "vars"."<name>" on every request, so fn must be safe for concurrent use.
+// If fn panics or its result cannot be encoded, the value is {"error": "..."}.
+// Publishing a name again replaces the previous function.
+// The returned function removes it, e.g. when a pool is stopped:
+//
+//	unpublish := pprof.Publish("gopool.mail", func() interface{} { return pool.Stats() })
+//	defer unpublish()
+func Publish(name string, fn func() interface{}) (unpublish func()) {
+	v := &publishedVar{fn: fn}
+	vars.Lock()
+	if vars.m == nil {
+		vars.m = map[string]*publishedVar{}
+	}
+	vars.m[name] = v
+	vars.Unlock()
+	return func() {
+		vars.Lock()
+		if vars.m[name] == v {
+			delete(vars.m, name)
+		}
+		vars.Unlock()
+	}
+}
+
+// Published returns the published names, sorted.
+func Published() []string {
+	vars.RLock()
+	defer vars.RUnlock()
+	names := make([]string, 0, len(vars.m))
+	for name := range vars.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GCPause is one garbage collection pause.
+type GCPause struct {
+	End   time.Time     `json:"end"`
+	Pause time.Duration `json:"pause_ns"`
+}
+
+// RuntimeStats is the document served by <PathPrefix>/vars.
+type RuntimeStats struct {
+	Time          time.Time `json:"time"`
+	StartTime     time.Time `json:"start_time"`
+	Uptime        string    `json:"uptime"`
+	UptimeSeconds float64   `json:"uptime_seconds"`
+	GoVersion     string    `json:"go_version"`
+	GOMAXPROCS    int       `json:"gomaxprocs"`
+	NumCPU        int       `json:"num_cpu"`
+	Goroutines    int       `json:"goroutines"`
+	// GCPauses are the most recent pauses kept by the runtime, at most 256, newest first.
+	GCPauses []GCPause        `json:"gc_pauses"`
+	MemStats runtime.MemStats `json:"memstats"`
+	// Build is nil when the binary was built without module support.
+	Build *debug.BuildInfo `json:"build,omitempty"`
+	// Vars are the values from Publish.
+	Vars map[string]interface{} `json:"vars,omitempty"`
+}
+
+// ReadRuntimeStats collects the runtime stats and the published values.
+// Reading the memory statistics briefly stops the world.
+func ReadRuntimeStats() *RuntimeStats {
+	now := time.Now()
+	st := &RuntimeStats{
+		Time:          now,
+		StartTime:     startTime,
+		Uptime:        now.Sub(startTime).Round(time.Second).String(),
+		UptimeSeconds: now.Sub(startTime).Seconds(),
+		GoVersion:     runtime.Version(),
+		GOMAXPROCS:    runtime.GOMAXPROCS(0),
+		NumCPU:        runtime.NumCPU(),
+		Goroutines:    runtime.NumGoroutine(),
+	}
+	runtime.ReadMemStats(&st.MemStats)
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		st.Build = bi
+	}
+
+	// PauseNs and PauseEnd are circular buffers, the latest GC at (NumGC+255)%256
+	ms := &st.MemStats
+	n := ms.NumGC
+	if n > 256 {
+		n = 256
+	}
+	st.GCPauses = make([]GCPause, 0, n)
+	for i := uint32(0); i < n; i++ {
+		j := (ms.NumGC - i + 255) % 256
+		st.GCPauses = append(st.GCPauses, GCPause{
+			End:   time.Unix(0, int64(ms.PauseEnd[j])),
+			Pause: time.Duration(ms.PauseNs[j]),
+		})
+	}
+
+	vars.RLock()
+	fns := make(map[string]func() interface{}, len(vars.m))
+	for name, v := range vars.m {
+		fns[name] = v.fn
+	}
+	vars.RUnlock()
+	// call outside the lock, a function may publish or unpublish
+	if len(fns) > 0 {
+		st.Vars = make(map[string]interface{}, len(fns))
+		for name, fn := range fns {
+			st.Vars[name] = readVar(fn)
+		}
+	}
+	return st
+}
+
+// readVar calls fn and checks that the result encodes, so one broken var does
+// not take the whole document down.
+func readVar(fn func() interface{}) (v interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			v = map[string]string{"error": fmt.Sprintf("panic: %v", r)}
+		}
+	}()
+	v = fn()
+	if _, err := json.Marshal(v); err != nil {
+		return map[string]string{"error": err.Error()}
+	}
+	return v
+}
+
+// varsHandler serves ReadRuntimeStats as JSON, like expvar's /debug/vars.
+func varsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(ReadRuntimeStats()); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	_, _ = w.Write(buf.Bytes())
+}
@@ -0,0 +1,122 @@
+package pprof
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/liuxiaodao666/go-util/gopool"
+)
+
+func TestVarsEndpoint(t *testing.T) {
+	pool := gopool.NewWorkerPool(2, 4, gopool.WithSyncMode())
+	unpublish := Publish("gopool.test", func() interface{} { return pool.Stats() })
+	defer unpublish()
+	runtime.GC()
+
+	h := NewServer(Config{}).Handler()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/vars", nil))
+	if w.Code != 200 || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("GET = %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	var got struct {
+		Goroutines int                       `json:"goroutines"`
+		GOMAXPROCS int                       `json:"gomaxprocs"`
+		Uptime     string                    `json:"uptime"`
+		GCPauses   []GCPause                 `json:"gc_pauses"`
+		MemStats   map[string]interface{}    `json:"memstats"`
+		Build      map[string]interface{}    `json:"build"`
+		Vars       map[string]map[string]int `json:"vars"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Goroutines <= 0 || got.GOMAXPROCS != runtime.GOMAXPROCS(0) || got.Uptime == "" {
+		t.Errorf("runtime fields = %+v", got)
+	}
+	if len(got.GCPauses) == 0 || got.GCPauses[0].End.IsZero() {
+		t.Errorf("gc pauses = %v", got.GCPauses)
+	}
+	if got.MemStats["NumGC"] == nil || got.MemStats["HeapAlloc"] == nil {
+		t.Error("memstats missing")
+	}
+	if got.Build == nil {
+		t.Error("build info missing")
+	}
+	want := map[string]int{"active_workers": 0, "pending_jobs": 0, "queued_jobs": 0}
+	if !reflect.DeepEqual(got.Vars["gopool.test"], want) {
+		t.Errorf("vars = %v", got.Vars)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/debug/pprof/vars", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST = %d, want 405", w.Code)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	first := Publish("test.value", func() interface{} { return 1 })
+	second := Publish("test.value", func() interface{} { return 2 })
+	if v := ReadRuntimeStats().Vars["test.value"]; v != 2 {
+		t.Errorf("value = %v, want the replacement", v)
+	}
+	// the replaced registration no longer owns the name
+	first()
+	if v := ReadRuntimeStats().Vars["test.value"]; v != 2 {
+		t.Errorf("value after stale unpublish = %v", v)
+	}
+	second()
+	for _, name := range Published() {
+		if name == "test.value" {
+			t.Error("still published after unpublish")
+		}
+	}
+}
+
+func TestGCPausesOrder(t *testing.T) {
+	runtime.GC()
+	runtime.GC()
+	st := ReadRuntimeStats()
+	if len(st.GCPauses) < 2 {
+		t.Fatalf("gc pauses = %d", len(st.GCPauses))
+	}
+	if st.GCPauses[0].End.Before(st.GCPauses[1].End) {
+		t.Errorf("not newest first: %v", st.GCPauses[:2])
+	}
+	if want := st.MemStats.NumGC; want <= 256 && uint32(len(st.GCPauses)) != want {
+		t.Errorf("gc pauses = %d, want %d", len(st.GCPauses), want)
+	}
+}
+
+func TestVarsBroken(t *testing.T) {
+	defer Publish("test.panic", func() interface{} { panic("boom") })()
+	defer Publish("test.unencodable", func() interface{} { return func() {} })()
+	defer Publish("test.ok", func() interface{} { return 1 })()
+
+	h := NewServer(Config{}).Handler()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/vars", nil))
+	if w.Code != 200 {
+		t.Fatalf("GET = %d: %s", w.Code, w.Body)
+	}
+	var got struct {
+		Vars map[string]interface{} `json:"vars"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Vars["test.ok"] != 1.0 {
+		t.Errorf("test.ok = %v", got.Vars["test.ok"])
+	}
+	for _, name := range []string{"test.panic", "test.unencodable"} {
+		m, ok := got.Vars[name].(map[string]interface{})
+		if !ok || m["error"] == nil || m["error"] == "" {
+			t.Errorf("%s = %v, want an error object", name, got.Vars[name])
+		}
+	}
+}